@@ -0,0 +1,4 @@
+data "cloudngfwaws_app_id_application" "example" {
+  version = "8509-7158"
+  name    = "web-browsing"
+}
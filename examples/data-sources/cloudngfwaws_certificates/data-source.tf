@@ -0,0 +1,13 @@
+data "cloudngfwaws_certificates" "example" {
+  rulestack = cloudngfwaws_rulestack.r.name
+}
+
+resource "cloudngfwaws_rulestack" "r" {
+  name        = "my-rulestack"
+  scope       = "Local"
+  account_id  = "12345"
+  description = "Made by Terraform"
+  profile_config {
+    anti_spyware = "BestPractice"
+  }
+}
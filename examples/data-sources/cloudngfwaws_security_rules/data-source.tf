@@ -0,0 +1,14 @@
+data "cloudngfwaws_security_rules" "example" {
+  rulestack = cloudngfwaws_rulestack.r.name
+  rule_list = "LocalRule"
+}
+
+resource "cloudngfwaws_rulestack" "r" {
+  name        = "my-rulestack"
+  scope       = "Local"
+  account_id  = "12345"
+  description = "Made by Terraform"
+  profile_config {
+    anti_spyware = "BestPractice"
+  }
+}
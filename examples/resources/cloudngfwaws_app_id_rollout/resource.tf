@@ -0,0 +1,23 @@
+resource "cloudngfwaws_app_id_rollout" "example" {
+  app_id_version = "8509-7158"
+  soak_time      = 1800
+
+  # Canary.
+  wave {
+    ngfw {
+      name       = "canary-instance"
+      account_id = "12345678"
+    }
+  }
+
+  wave {
+    ngfw {
+      name       = "instance-1"
+      account_id = "12345678"
+    }
+    ngfw {
+      name       = "instance-2"
+      account_id = "12345678"
+    }
+  }
+}
@@ -48,3 +48,10 @@ resource "aws_subnet" "subnet2" {
     Name = "tf-example"
   }
 }
+
+# Send traffic in us-west-2a through the firewall endpoint in that zone.
+resource "aws_route" "example" {
+  route_table_id         = aws_route_table.example.id
+  destination_cidr_block = "0.0.0.0/0"
+  vpc_endpoint_id        = cloudngfwaws_ngfw.example.endpoint_ids["us-west-2a"]
+}
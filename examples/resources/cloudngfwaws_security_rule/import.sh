@@ -1,2 +1,2 @@
-# import name is <rulestack>:<rule_list>:<priority>
-terraform import cloudngfwaws_security_rule.example terraform-rulestack:LocalRule:3
+# import name is <rulestack>:<rule_list>:<name>
+terraform import cloudngfwaws_security_rule.example terraform-rulestack:LocalRule:tf-security-rule
@@ -0,0 +1,2 @@
+# import name is <rulestack>:<rule_list>
+terraform import cloudngfwaws_security_rules.example terraform-rulestack:LocalRule
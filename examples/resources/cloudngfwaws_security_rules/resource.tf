@@ -0,0 +1,42 @@
+resource "cloudngfwaws_security_rules" "example" {
+  rulestack = cloudngfwaws_rulestack.r.name
+  rule_list = "LocalRule"
+
+  rule {
+    name        = "allow-web"
+    description = "Configured by Terraform"
+    source {
+      cidrs = ["any"]
+    }
+    destination {
+      cidrs = ["192.168.0.0/16"]
+    }
+    applications = ["web-browsing", "ssl"]
+    category {}
+    action        = "Allow"
+    audit_comment = "initial config"
+  }
+
+  rule {
+    name = "deny-all"
+    source {
+      cidrs = ["any"]
+    }
+    destination {
+      cidrs = ["any"]
+    }
+    applications = ["any"]
+    category {}
+    action = "DenySilent"
+  }
+}
+
+resource "cloudngfwaws_rulestack" "r" {
+  name        = "terraform-rulestack"
+  scope       = "Local"
+  account_id  = "123456789"
+  description = "Made by Terraform"
+  profile_config {
+    anti_spyware = "BestPractice"
+  }
+}
@@ -0,0 +1,392 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
+	ngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/firewall"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// Resource.
+func resourceAppIdRollout() *schema.Resource {
+	return &schema.Resource{
+		Description: "Resource for upgrading the App-ID version of NGFWs in waves, halting at the first wave that fails.",
+
+		CreateContext: createAppIdRollout,
+		ReadContext:   readAppIdRollout,
+		UpdateContext: updateAppIdRollout,
+		DeleteContext: deleteAppIdRollout,
+
+		CustomizeDiff: customizeDiffAppIdRollout,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+			Update: schema.DefaultTimeout(60 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"app_id_version": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The App-ID version to upgrade to.",
+			},
+			"wave": {
+				Type:        schema.TypeList,
+				Required:    true,
+				MinItems:    1,
+				Description: "The waves of NGFWs, in upgrade order.  Each wave starts once every NGFW in the previous wave, including any already at the version, reports the new version and is healthy.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"ngfw": {
+							Type:        schema.TypeList,
+							Required:    true,
+							MinItems:    1,
+							Description: "The NGFWs upgraded together.",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"name": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "The NGFW name.",
+									},
+									"account_id": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "The account ID.",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"soak_time": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Description:  "Seconds to wait after a wave is upgraded and healthy before upgrading the NGFWs of the next one.  When a halted rollout is applied again, only the rest of the soak time is waited.",
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"completed_waves": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The number of leading waves whose NGFWs are all at the App-ID version.",
+			},
+			"wave_completed_at": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "When each of the completed waves was last upgraded and healthy, in RFC 3339 format, so that a halted rollout applied again only waits for the rest of `soak_time`.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"ngfw_versions": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "The current App-ID version of each NGFW, keyed by `<account_id>:<name>`.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func createAppIdRollout(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	d.SetId(resource.UniqueId())
+
+	return rollOutAppIdVersion(ctx, d, meta, d.Timeout(schema.TimeoutCreate))
+}
+
+func readAppIdRollout(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := ngfw.NewClient(con)
+	version := d.Get("app_id_version").(string)
+
+	tflog.Info(
+		ctx, "read app-id rollout",
+		"app_id_version", version,
+	)
+
+	versions := make(map[string]interface{})
+	completed := 0
+	done := true
+	for _, wave := range loadAppIdRolloutWaves(d.Get("wave")) {
+		for _, fw := range wave {
+			res, err := svc.Read(ctx, fw)
+			if err != nil {
+				if isObjectNotFound(err) {
+					done = false
+					continue
+				}
+				return diag.FromErr(err)
+			}
+
+			v := res.Response.Firewall.AppIdVersion
+			versions[buildNgfwId(fw.AccountId, fw.Name)] = v
+			if v != version {
+				done = false
+			}
+		}
+		if done {
+			completed++
+		}
+	}
+
+	// Waves that are no longer completed have to soak again.
+	times, _ := d.Get("wave_completed_at").([]interface{})
+	if len(times) > completed {
+		times = times[:completed]
+	}
+
+	d.Set("completed_waves", completed)
+	d.Set("wave_completed_at", times)
+	d.Set("ngfw_versions", versions)
+
+	return nil
+}
+
+func updateAppIdRollout(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return rollOutAppIdVersion(ctx, d, meta, d.Timeout(schema.TimeoutUpdate))
+}
+
+func deleteAppIdRollout(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// The NGFWs keep their App-ID version.
+	d.SetId("")
+	return nil
+}
+
+// customizeDiffAppIdRollout plans the rollout again until every NGFW is at
+// the App-ID version, such as after a halted rollout.
+func customizeDiffAppIdRollout(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.NewValueKnown("app_id_version") {
+		con, err := apiClient(ctx, d, meta)
+		if err != nil {
+			return err
+		}
+		version := d.Get("app_id_version").(string)
+		for i, wave := range loadAppIdRolloutWaves(d.Get("wave")) {
+			for j, fw := range wave {
+				key := fmt.Sprintf("wave.%d.ngfw.%d.", i, j)
+				if !d.NewValueKnown(key+"name") || !d.NewValueKnown(key+"account_id") {
+					continue
+				}
+				if err = claimNgfwAppIdVersion(meta, con, fw.AccountId, fw.Name, appIdOwnerRollout, version); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if d.Id() == "" {
+		return nil
+	}
+
+	pending := d.HasChange("app_id_version") || d.HasChange("wave")
+	if !pending {
+		version := d.Get("app_id_version").(string)
+		versions := d.Get("ngfw_versions").(map[string]interface{})
+		for _, wave := range loadAppIdRolloutWaves(d.Get("wave")) {
+			for _, fw := range wave {
+				if v, _ := versions[buildNgfwId(fw.AccountId, fw.Name)].(string); v != version {
+					pending = true
+				}
+			}
+		}
+	}
+
+	if pending {
+		for _, key := range []string{"completed_waves", "wave_completed_at", "ngfw_versions"} {
+			if err := d.SetNewComputed(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// rollOutAppIdVersion upgrades the NGFWs that are not at the App-ID version
+// yet, one wave at a time.  Once a wave fails the remaining waves are left
+// alone.  When each wave was completed is saved, so that applying a halted
+// rollout again only soaks the previous wave for the rest of the soak time.
+func rollOutAppIdVersion(ctx context.Context, d *schema.ResourceData, meta interface{}, timeout time.Duration) diag.Diagnostics {
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := ngfw.NewClient(con)
+	version := d.Get("app_id_version").(string)
+	soak := time.Duration(d.Get("soak_time").(int)) * time.Second
+	waves := loadAppIdRolloutWaves(d.Get("wave"))
+
+	// The completion times are unknown in the plan, so they are taken from
+	// the state, unless they were for another version or other waves.
+	var times []string
+	if !d.HasChange("app_id_version") && !d.HasChange("wave") {
+		prev, _ := d.GetChange("wave_completed_at")
+		list, _ := prev.([]interface{})
+		for _, x := range list {
+			v, _ := x.(string)
+			times = append(times, v)
+		}
+	}
+	complete := func(wave int, upgraded bool) {
+		for len(times) <= wave {
+			times = append(times, "")
+		}
+		if upgraded || times[wave] == "" {
+			times[wave] = time.Now().UTC().Format(time.RFC3339)
+		}
+		d.Set("wave_completed_at", times[:wave+1])
+	}
+
+	halt := func(wave int, name string, diags diag.Diagnostics) diag.Diagnostics {
+		for i := range diags {
+			diags[i].Summary = fmt.Sprintf("Halted App-ID rollout to %q at wave %d, ngfw %q: %s", version, wave+1, name, diags[i].Summary)
+		}
+		return append(diags, readAppIdRollout(ctx, d, meta)...)
+	}
+
+	for i, wave := range waves {
+		tflog.Info(
+			ctx, "app-id rollout wave",
+			"wave", i+1,
+			"app_id_version", version,
+			"ngfws", len(wave),
+		)
+
+		var pending []ngfw.Info
+		for _, fw := range wave {
+			res, err := svc.Read(ctx, fw)
+			if err != nil {
+				return halt(i, fw.Name, diag.FromErr(err))
+			}
+			if res.Response.Firewall.AppIdVersion != version {
+				pending = append(pending, ngfw.Info{
+					Name:                         fw.Name,
+					AccountId:                    fw.AccountId,
+					AppIdVersion:                 version,
+					AutomaticUpgradeAppIdVersion: res.Response.Firewall.AutomaticUpgradeAppIdVersion,
+				})
+			}
+		}
+
+		// The previous wave soaks before anything in this wave is upgraded,
+		// even when this rollout is applied again after halting.
+		if soak > 0 && i > 0 && len(pending) > 0 {
+			wait := soak
+			if done, err := time.Parse(time.RFC3339, times[i-1]); err == nil {
+				wait -= time.Since(done)
+			}
+			if wait > 0 {
+				tflog.Info(
+					ctx, "app-id rollout soak",
+					"wave", i,
+					"wait", wait.String(),
+				)
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return diag.Errorf("Halted App-ID rollout to %q after wave %d: %s", version, i, ctx.Err())
+				case <-timer.C:
+				}
+			}
+		}
+
+		for _, o := range pending {
+			if err := svc.UpdateNGFirewallContentVersion(ctx, o); err != nil {
+				return halt(i, o.Name, diag.FromErr(err))
+			}
+		}
+
+		for _, o := range pending {
+			if diags := waitForNgfwAppIdVersion(ctx, svc, o, timeout); diags.HasError() {
+				return halt(i, o.Name, diags)
+			}
+		}
+
+		// Every NGFW in the wave has to be healthy, including the ones that
+		// were already at the App-ID version.
+		for _, fw := range wave {
+			if diags := waitForNgfw(ctx, svc, fw.AccountId, fw.Name, timeout); diags.HasError() {
+				return halt(i, fw.Name, diags)
+			}
+		}
+
+		complete(i, len(pending) > 0)
+	}
+
+	return readAppIdRollout(ctx, d, meta)
+}
+
+// App-ID version ownership.
+const (
+	appIdOwnerNgfw    = "cloudngfwaws_ngfw"
+	appIdOwnerRollout = "cloudngfwaws_app_id_rollout"
+)
+
+// claimNgfwAppIdVersion records the App-ID version that the given resource
+// type sets on the firewall, returning an error if cloudngfwaws_ngfw sets a
+// different version than a rollout, as each would revert the other.
+func claimNgfwAppIdVersion(meta interface{}, con *awsngfw.Client, aid, name, owner, version string) error {
+	p := meta.(*providerMeta)
+	key := ngfwTagKey{con, aid, name}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.appIdOwners == nil {
+		p.appIdOwners = make(map[ngfwTagKey]map[string]string)
+	}
+	owners := p.appIdOwners[key]
+	if owners == nil {
+		owners = make(map[string]string)
+		p.appIdOwners[key] = owners
+	}
+	owners[owner] = version
+
+	pinned, ok1 := owners[appIdOwnerNgfw]
+	rollout, ok2 := owners[appIdOwnerRollout]
+	if !ok1 || !ok2 || pinned == rollout {
+		return nil
+	}
+
+	return fmt.Errorf("ngfw %q: app_id_version %q in %s conflicts with the rollout of App-ID version %q by %s, and each would revert the other; remove app_id_version from %s", name, pinned, appIdOwnerNgfw, rollout, appIdOwnerRollout, appIdOwnerNgfw)
+}
+
+// Schema handling.
+func loadAppIdRolloutWaves(v interface{}) [][]ngfw.ReadInput {
+	list, _ := v.([]interface{})
+	waves := make([][]ngfw.ReadInput, 0, len(list))
+
+	for _, x := range list {
+		w, _ := x.(map[string]interface{})
+		fws, _ := w["ngfw"].([]interface{})
+		wave := make([]ngfw.ReadInput, 0, len(fws))
+		for _, y := range fws {
+			fw, _ := y.(map[string]interface{})
+			name, _ := fw["name"].(string)
+			aid, _ := fw["account_id"].(string)
+			wave = append(wave, ngfw.ReadInput{
+				Name:      name,
+				AccountId: aid,
+			})
+		}
+		waves = append(waves, wave)
+	}
+
+	return waves
+}
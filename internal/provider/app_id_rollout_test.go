@@ -0,0 +1,167 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// Resource.
+func TestResourceAppIdRolloutOffline(t *testing.T) {
+	testFastNgfwWaits(t)
+
+	m := newMockApi(t)
+	for _, name := range []string{"canary", "fw1", "fw2", "fw3"} {
+		m.addFirewall(name).Firewall["AppIdVersion"] = "8500-7000"
+	}
+	m.Firewall("fw2").FailUpdates = true
+	l := newTestLifecycle(t, resourceAppIdRollout(), m.meta())
+
+	wave := func(names ...string) map[string]interface{} {
+		list := make([]interface{}, 0, len(names))
+		for _, name := range names {
+			list = append(list, map[string]interface{}{
+				"name":       name,
+				"account_id": mockAccountId,
+			})
+		}
+		return map[string]interface{}{"ngfw": list}
+	}
+
+	raw := map[string]interface{}{
+		"app_id_version": "8509-7158",
+		"wave": []interface{}{
+			wave("canary"),
+			wave("fw1", "fw2"),
+			wave("fw3"),
+		},
+	}
+
+	// The failed wave halts the rollout.
+	diags := l.applyDiags(raw)
+	if !diags.HasError() {
+		t.Fatalf("Rollout with a failing ngfw did not return an error")
+	}
+	if msg := diagsToString(diags); !strings.Contains(msg, "wave 2") || !strings.Contains(msg, "UPDATE_FAILED") {
+		t.Errorf("Error does not name the failed wave and status: %s", msg)
+	}
+	if v := m.Firewall("fw3").Firewall["AppIdVersion"]; v != "8500-7000" {
+		t.Errorf("ngfw after the failed wave was upgraded to %v", v)
+	}
+	if v := m.Firewall("canary").Firewall["AppIdVersion"]; v != "8509-7158" {
+		t.Errorf("Canary is at version %v", v)
+	}
+
+	if n := l.state.Attributes["wave_completed_at.#"]; n != "1" {
+		t.Fatalf("Got %s wave completion times after the failed wave", n)
+	}
+	if _, err := time.Parse(time.RFC3339, l.state.Attributes["wave_completed_at.0"]); err != nil {
+		t.Errorf("Canary completion time is invalid: %s", err)
+	}
+
+	// Once the ngfw is fixed, the rollout carries on.
+	fw := m.Firewall("fw2")
+	fw.FailUpdates = false
+	fw.Status = "UPDATE_COMPLETE"
+	l.apply(raw)
+	l.check(map[string]string{
+		"completed_waves":     "3",
+		"wave_completed_at.#": "3",
+		"ngfw_versions." + buildNgfwId(mockAccountId, "fw3"): "8509-7158",
+	})
+}
+
+func TestResourceAppIdRolloutSoakOffline(t *testing.T) {
+	testFastNgfwWaits(t)
+
+	m := newMockApi(t)
+	m.addFirewall("canary").Firewall["AppIdVersion"] = "8509-7158"
+	m.addFirewall("fw1").Firewall["AppIdVersion"] = "8500-7000"
+	l := newTestLifecycle(t, resourceAppIdRollout(), m.meta())
+
+	raw := map[string]interface{}{
+		"app_id_version": "8509-7158",
+		"soak_time":      3600,
+		"wave": []interface{}{
+			map[string]interface{}{"ngfw": []interface{}{
+				map[string]interface{}{"name": "canary", "account_id": mockAccountId},
+			}},
+			map[string]interface{}{"ngfw": []interface{}{
+				map[string]interface{}{"name": "fw1", "account_id": mockAccountId},
+			}},
+		},
+	}
+
+	// A rollout that halted at the second wave after the canary had
+	// already soaked for longer than the soak time.
+	soaked := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	l.state = &terraform.InstanceState{
+		ID: "rollout",
+		Attributes: map[string]string{
+			"id":                       "rollout",
+			"app_id_version":           "8509-7158",
+			"soak_time":                "3600",
+			"completed_waves":          "1",
+			"wave_completed_at.#":      "1",
+			"wave_completed_at.0":      soaked,
+			"wave.#":                   "2",
+			"wave.0.ngfw.#":            "1",
+			"wave.0.ngfw.0.name":       "canary",
+			"wave.0.ngfw.0.account_id": mockAccountId,
+			"wave.1.ngfw.#":            "1",
+			"wave.1.ngfw.0.name":       "fw1",
+			"wave.1.ngfw.0.account_id": mockAccountId,
+		},
+	}
+
+	// Applying it again does not soak the canary again.
+	l.apply(raw)
+	l.check(map[string]string{
+		"completed_waves":     "2",
+		"wave_completed_at.#": "2",
+		"wave_completed_at.0": soaked,
+	})
+}
+
+func TestResourceAppIdRolloutUnhealthyOffline(t *testing.T) {
+	testFastNgfwWaits(t)
+
+	m := newMockApi(t)
+	m.addFirewall("canary").Firewall["AppIdVersion"] = "8509-7158"
+	m.addFirewall("fw1").Firewall["AppIdVersion"] = "8500-7000"
+	m.Firewall("canary").Status = "UPDATE_FAILED"
+	meta := m.meta()
+	l := newTestLifecycle(t, resourceAppIdRollout(), meta)
+
+	raw := map[string]interface{}{
+		"app_id_version": "8509-7158",
+		"wave": []interface{}{
+			map[string]interface{}{"ngfw": []interface{}{
+				map[string]interface{}{"name": "canary", "account_id": mockAccountId},
+			}},
+			map[string]interface{}{"ngfw": []interface{}{
+				map[string]interface{}{"name": "fw1", "account_id": mockAccountId},
+			}},
+		},
+	}
+
+	// An ngfw already at the version is still health checked.
+	diags := l.applyDiags(raw)
+	if msg := diagsToString(diags); !strings.Contains(msg, "wave 1") || !strings.Contains(msg, "UPDATE_FAILED") {
+		t.Errorf("Unhealthy ngfw already at the version did not halt the rollout: %s", msg)
+	}
+	if v := m.Firewall("fw1").Firewall["AppIdVersion"]; v != "8500-7000" {
+		t.Errorf("ngfw after the unhealthy wave was upgraded to %v", v)
+	}
+
+	// Pinning another version in cloudngfwaws_ngfw is a conflict.
+	con := meta.(*providerMeta).client
+	if err := claimNgfwAppIdVersion(meta, con, mockAccountId, "fw1", appIdOwnerNgfw, "8509-7158"); err != nil {
+		t.Errorf("Pinning the rollout version is a conflict: %s", err)
+	}
+	if err := claimNgfwAppIdVersion(meta, con, mockAccountId, "fw1", appIdOwnerNgfw, "8500-7000"); err == nil || !strings.Contains(err.Error(), "would revert") {
+		t.Errorf("Pinning another version is not a conflict: %v", err)
+	}
+}
@@ -2,11 +2,16 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/appid"
+	ngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/firewall"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
@@ -32,6 +37,8 @@ func dataSourceAppIdVersions() *schema.Resource {
 				Optional:    true,
 				Description: "Pagination token.",
 			},
+			"all_pages":         allPagesSchema(),
+			"max_total_results": maxTotalResultsSchema(),
 			"next_token": {
 				Type:        schema.TypeString,
 				Computed:    true,
@@ -64,20 +71,34 @@ func readAppIdVersions(ctx context.Context, d *schema.ResourceData, meta interfa
 		"token", input.NextToken,
 	)
 
-	svc := appid.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := appid.NewClient(con)
 
-	ans, err := svc.List(ctx, input)
+	var versions []string
+	next, err := readPages(input.NextToken, d.Get("all_pages").(bool), d.Get("max_total_results").(int), func(token string) (int, string, error) {
+		input.NextToken = token
+		ans, err := svc.List(ctx, input)
+		if err != nil {
+			return 0, "", err
+		}
+
+		versions = append(versions, ans.Response.Versions...)
+		return len(ans.Response.Versions), ans.Response.NextToken, nil
+	})
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
 	d.SetId(strings.Join(
-		[]string{strconv.Itoa(input.MaxResults), input.NextToken},
+		[]string{strconv.Itoa(input.MaxResults), d.Get("token").(string)},
 		IdSeparator,
 	))
 
-	d.Set("versions", ans.Response.Versions)
-	d.Set("next_token", ans.Response.NextToken)
+	d.Set("versions", versions)
+	d.Set("next_token", next)
 	return nil
 }
 
@@ -105,6 +126,8 @@ func dataSourceAppIdVersion() *schema.Resource {
 				Optional:    true,
 				Description: "Pagination token.",
 			},
+			"all_pages":         allPagesSchema(),
+			"max_total_results": maxTotalResultsSchema(),
 			"next_token": {
 				Type:        schema.TypeString,
 				Computed:    true,
@@ -140,9 +163,23 @@ func readAppIdVersion(ctx context.Context, d *schema.ResourceData, meta interfac
 		"token", input.NextToken,
 	)
 
-	svc := appid.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := appid.NewClient(con)
 
-	ans, err := svc.Read(ctx, input)
+	var apps []string
+	next, err := readPages(input.NextToken, d.Get("all_pages").(bool), d.Get("max_total_results").(int), func(token string) (int, string, error) {
+		input.NextToken = token
+		ans, err := svc.Read(ctx, input)
+		if err != nil {
+			return 0, "", err
+		}
+
+		apps = append(apps, ans.Response.Applications...)
+		return len(ans.Response.Applications), ans.Response.NextToken, nil
+	})
 	if err != nil {
 		if isObjectNotFound(err) {
 			d.SetId("")
@@ -152,10 +189,488 @@ func readAppIdVersion(ctx context.Context, d *schema.ResourceData, meta interfac
 	}
 
 	d.SetId(input.Version)
-	d.Set("next_token", ans.Response.NextToken)
-	d.Set("applications", ans.Response.Applications)
+	d.Set("next_token", next)
+	d.Set("applications", apps)
 
 	return nil
 }
 
 // Data source (app-id application).
+func dataSourceAppIdApplication() *schema.Resource {
+	return &schema.Resource{
+		Description: "Data source to retrieve information on an application in a given AppId version.",
+
+		ReadContext: readAppIdApplication,
+
+		Schema: map[string]*schema.Schema{
+			"version": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The AppId version.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The application name.",
+			},
+			"category": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The category.",
+			},
+			"subcategory": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The subcategory.",
+			},
+			"technology": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The technology.",
+			},
+			"risk": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The risk, from 1 (lowest) to 5 (highest).",
+			},
+			"default_port": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The ports the application uses by default, which is what a `protocol` of `application-default` allows.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"protocol": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The protocol, such as `tcp` or `udp`.",
+						},
+						"ports": {
+							Type:        schema.TypeList,
+							Computed:    true,
+							Description: "The ports and port ranges, such as `443` or `6000-6010`.",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+			"dependencies": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The applications that must also be allowed for this application to work.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"implicit_applications": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The applications that are implicitly allowed along with this application.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func readAppIdApplication(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	version := d.Get("version").(string)
+	name := d.Get("name").(string)
+
+	tflog.Info(
+		ctx, "read appid application",
+		"version", version,
+		"name", name,
+	)
+
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	o, err := readAppIdApplicationDetails(ctx, con, version, name)
+	if err != nil {
+		if isObjectNotFound(err) {
+			d.SetId("")
+			return nil
+		}
+		return diag.FromErr(err)
+	}
+
+	d.SetId(strings.Join([]string{version, name}, IdSeparator))
+	d.Set("category", o.Category)
+	d.Set("subcategory", o.SubCategory)
+	d.Set("technology", o.Technology)
+	d.Set("risk", o.Risk)
+	d.Set("default_port", dumpAppIdDefaultPorts(o.DefaultPorts))
+	d.Set("dependencies", o.Dependencies)
+	d.Set("implicit_applications", o.ImplicitApplications)
+
+	return nil
+}
+
+// appIdApplicationDetails is an application in an App-ID version.
+type appIdApplicationDetails struct {
+	Category             string   `json:"Category"`
+	SubCategory          string   `json:"SubCategory"`
+	Technology           string   `json:"Technology"`
+	Risk                 int      `json:"Risk"`
+	DefaultPorts         []string `json:"DefaultPorts"`
+	Dependencies         []string `json:"Dependencies"`
+	ImplicitApplications []string `json:"ImplicitApplications"`
+}
+
+// readAppIdApplicationDetails returns an application in an App-ID version.
+// The SDK has no func for this, so the API is called directly.
+func readAppIdApplicationDetails(ctx context.Context, con *awsngfw.Client, version, name string) (appIdApplicationDetails, error) {
+	var ans appIdApplicationDetails
+
+	path := fmt.Sprintf("/v1/config/appidversions/%s/appname/%s", url.PathEscape(version), url.PathEscape(name))
+	err := apiCall(ctx, con, http.MethodGet, path, con.RulestackJwt, nil, &ans)
+
+	return ans, err
+}
+
+// dumpAppIdDefaultPorts groups default ports such as "tcp/80,443" by
+// protocol, in the order the protocols are first seen.
+func dumpAppIdDefaultPorts(list []string) []interface{} {
+	if len(list) == 0 {
+		return nil
+	}
+
+	ports := make(map[string][]interface{})
+	var protocols []string
+	for _, x := range list {
+		protocol, value := x, ""
+		if i := strings.Index(x, "/"); i >= 0 {
+			protocol, value = x[:i], x[i+1:]
+		}
+		protocol = strings.ToLower(strings.TrimSpace(protocol))
+		if _, ok := ports[protocol]; !ok {
+			ports[protocol] = []interface{}{}
+			protocols = append(protocols, protocol)
+		}
+		for _, port := range strings.Split(value, ",") {
+			if port = strings.TrimSpace(port); port != "" {
+				ports[protocol] = append(ports[protocol], port)
+			}
+		}
+	}
+
+	ans := make([]interface{}, 0, len(protocols))
+	for _, protocol := range protocols {
+		ans = append(ans, map[string]interface{}{
+			"protocol": protocol,
+			"ports":    ports[protocol],
+		})
+	}
+
+	return ans
+}
+
+// App-ID version handling.
+
+// appIdKey is an App-ID version, along with the client that reads it.
+type appIdKey struct {
+	con     *awsngfw.Client
+	version string
+}
+
+// appIdVersionsKey is the list of App-ID versions of a client.
+type appIdVersionsKey struct {
+	con *awsngfw.Client
+}
+
+// appIdRulestackKey is the App-ID versions that the NGFWs using a rulestack
+// run, along with the client that reads them.
+type appIdRulestackKey struct {
+	con       *awsngfw.Client
+	rulestack string
+}
+
+// appIdRead is a read of App-ID data, which callers wanting the same data
+// wait for instead of reading it again.
+type appIdRead struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// cachedAppIdData returns the data of the given key, calling fn to read it
+// once per provider.  The read is done without holding appIdMu, so reads of
+// other keys are not held up by it.  Failed reads are not cached, and the
+// callers that waited for one read the data again themselves.
+func cachedAppIdData(ctx context.Context, meta interface{}, key interface{}, fn func() (interface{}, error)) (interface{}, error) {
+	p := meta.(*providerMeta)
+
+	for {
+		p.appIdMu.Lock()
+		r, ok := p.appIdReads[key]
+		if !ok {
+			if p.appIdReads == nil {
+				p.appIdReads = make(map[interface{}]*appIdRead)
+			}
+			r = &appIdRead{done: make(chan struct{})}
+			p.appIdReads[key] = r
+		}
+		p.appIdMu.Unlock()
+
+		if !ok {
+			r.value, r.err = fn()
+			if r.err != nil {
+				p.appIdMu.Lock()
+				delete(p.appIdReads, key)
+				p.appIdMu.Unlock()
+			}
+			close(r.done)
+			return r.value, r.err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-r.done:
+		}
+		if r.err == nil {
+			return r.value, nil
+		}
+	}
+}
+
+// cachedAppIdVersions returns every available App-ID version, reading them
+// once per provider.
+func cachedAppIdVersions(ctx context.Context, meta interface{}, con *awsngfw.Client) ([]string, error) {
+	v, err := cachedAppIdData(ctx, meta, appIdVersionsKey{con}, func() (interface{}, error) {
+		return listAppIdVersions(ctx, appid.NewClient(con))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]string), nil
+}
+
+// cachedAppIdApplications returns the applications in the App-ID version,
+// reading them once per provider.
+func cachedAppIdApplications(ctx context.Context, meta interface{}, con *awsngfw.Client, version string) (map[string]bool, error) {
+	v, err := cachedAppIdData(ctx, meta, appIdKey{con, version}, func() (interface{}, error) {
+		tflog.Info(
+			ctx, "read appid applications",
+			"version", version,
+		)
+
+		svc := appid.NewClient(con)
+		input := appid.ReadInput{
+			Version:    version,
+			MaxResults: 1000,
+		}
+		apps := make(map[string]bool)
+		_, err := readPages("", true, 0, func(token string) (int, string, error) {
+			input.NextToken = token
+			ans, err := svc.Read(ctx, input)
+			if err != nil {
+				return 0, "", err
+			}
+
+			for _, app := range ans.Response.Applications {
+				apps[app] = true
+			}
+			return len(ans.Response.Applications), ans.Response.NextToken, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return apps, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(map[string]bool), nil
+}
+
+// cachedRulestackAppIdVersions returns the App-ID versions that the NGFWs
+// using the rulestack run, reading them once per provider.
+func cachedRulestackAppIdVersions(ctx context.Context, meta interface{}, con *awsngfw.Client, rulestack string) ([]string, error) {
+	v, err := cachedAppIdData(ctx, meta, appIdRulestackKey{con, rulestack}, func() (interface{}, error) {
+		tflog.Info(
+			ctx, "read rulestack appid versions",
+			RulestackName, rulestack,
+		)
+
+		svc := ngfw.NewClient(con)
+		input := ngfw.ListInput{
+			MaxResults: 100,
+		}
+		var fws []ngfw.ReadInput
+		_, err := readPages("", true, 0, func(token string) (int, string, error) {
+			input.NextToken = token
+			ans, err := svc.List(ctx, input)
+			if err != nil {
+				return 0, "", err
+			}
+
+			for _, x := range ans.Response.Firewalls {
+				fws = append(fws, ngfw.ReadInput{
+					Name:      x.Name,
+					AccountId: x.AccountId,
+				})
+			}
+			return len(ans.Response.Firewalls), ans.Response.NextToken, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var versions []string
+		seen := make(map[string]bool)
+		for _, fw := range fws {
+			res, err := svc.Read(ctx, fw)
+			if err != nil {
+				if isObjectNotFound(err) {
+					continue
+				}
+				return nil, err
+			}
+
+			o := res.Response.Firewall
+			if o.RuleStackName != rulestack && o.GlobalRuleStackName != rulestack {
+				continue
+			}
+			if v := o.AppIdVersion; v != "" && !seen[v] {
+				seen[v] = true
+				versions = append(versions, v)
+			}
+		}
+		sort.Strings(versions)
+
+		return versions, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]string), nil
+}
+
+// checkApplications returns an error naming each application that is not in
+// an App-ID version that the NGFWs using the rulestack run, along with its
+// closest match.  When no NGFW uses the rulestack, this is checked against
+// the rulestack's minimum App-ID version if it has one, otherwise the latest
+// App-ID version.
+func checkApplications(ctx context.Context, meta interface{}, con *awsngfw.Client, rulestack string, apps []string) error {
+	if len(apps) == 0 {
+		return nil
+	}
+
+	versions, err := cachedRulestackAppIdVersions(ctx, meta, con, rulestack)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		version, err := rulestackMinAppIdVersion(ctx, con, rulestack)
+		if err != nil {
+			return err
+		}
+		if version == "" {
+			list, err := cachedAppIdVersions(ctx, meta, con)
+			if err != nil {
+				return err
+			}
+			for _, v := range list {
+				if version == "" || compareAppIdVersions(v, version) > 0 {
+					version = v
+				}
+			}
+			if version == "" {
+				return nil
+			}
+		}
+		versions = []string{version}
+	}
+
+	var msgs []string
+	for _, version := range versions {
+		known, err := cachedAppIdApplications(ctx, meta, con, version)
+		if err != nil {
+			return err
+		}
+
+		var names []string
+		for _, app := range apps {
+			if app == "any" || known[app] {
+				continue
+			}
+			if names == nil {
+				names = make([]string, 0, len(known))
+				for name := range known {
+					names = append(names, name)
+				}
+			}
+			msg := fmt.Sprintf("application %q is not in App-ID version %q", app, version)
+			if match := closestMatch(app, names); match != "" {
+				msg += fmt.Sprintf(", did you mean %q?", match)
+			}
+			msgs = append(msgs, msg)
+		}
+	}
+
+	if len(msgs) > 0 {
+		return fmt.Errorf("%s", strings.Join(msgs, "; "))
+	}
+
+	return nil
+}
+
+// listAppIdVersions returns every available App-ID version.
+func listAppIdVersions(ctx context.Context, svc *appid.Client) ([]string, error) {
+	input := appid.ListInput{
+		MaxResults: 100,
+	}
+
+	var versions []string
+	_, err := readPages("", true, 0, func(token string) (int, string, error) {
+		input.NextToken = token
+		ans, err := svc.List(ctx, input)
+		if err != nil {
+			return 0, "", err
+		}
+
+		versions = append(versions, ans.Response.Versions...)
+		return len(ans.Response.Versions), ans.Response.NextToken, nil
+	})
+
+	return versions, err
+}
+
+// compareAppIdVersions compares App-ID versions such as "8509-7158" by each
+// of their numbers, returning -1, 0 or 1.
+func compareAppIdVersions(a, b string) int {
+	x, y := strings.Split(a, "-"), strings.Split(b, "-")
+	for i := 0; i < len(x) && i < len(y); i++ {
+		n, errN := strconv.Atoi(x[i])
+		m, errM := strconv.Atoi(y[i])
+		if errN != nil || errM != nil {
+			if c := strings.Compare(x[i], y[i]); c != 0 {
+				return c
+			}
+			continue
+		}
+		if n < m {
+			return -1
+		} else if n > m {
+			return 1
+		}
+	}
+
+	switch {
+	case len(x) < len(y):
+		return -1
+	case len(x) > len(y):
+		return 1
+	}
+	return 0
+}
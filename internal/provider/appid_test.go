@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestCompareAppIdVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"8509-7158", "8509-7158", 0},
+		{"8500-7000", "8509-7158", -1},
+		{"8509-7158", "8509-700", 1},
+		{"10000-1", "9999-9999", 1},
+		{"8509", "8509-1", -1},
+	} {
+		if got := compareAppIdVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("%q vs %q: got %d, expected %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestDataSourceAppIdApplicationOffline(t *testing.T) {
+	m := newMockApi(t)
+	m.AppIdVersions["8509-7158"] = []string{"ssl", "web-browsing"}
+	m.AppIdApplications["web-browsing"] = map[string]interface{}{
+		"Category":             "general-internet",
+		"SubCategory":          "internet-utility",
+		"Technology":           "browser-based",
+		"Risk":                 4,
+		"DefaultPorts":         []string{"tcp/80", "TCP/8080, 8000-8010", "udp/dynamic"},
+		"Dependencies":         []string{},
+		"ImplicitApplications": []string{"ssl"},
+	}
+
+	attrs := testReadDataSource(t, dataSourceAppIdApplication(), m.meta(), map[string]interface{}{
+		"version": "8509-7158",
+		"name":    "web-browsing",
+	})
+	for key, want := range map[string]string{
+		"id":                      "8509-7158" + IdSeparator + "web-browsing",
+		"category":                "general-internet",
+		"subcategory":             "internet-utility",
+		"technology":              "browser-based",
+		"risk":                    "4",
+		"default_port.#":          "2",
+		"default_port.0.protocol": "tcp",
+		"default_port.0.ports.#":  "3",
+		"default_port.0.ports.0":  "80",
+		"default_port.0.ports.1":  "8080",
+		"default_port.0.ports.2":  "8000-8010",
+		"default_port.1.protocol": "udp",
+		"default_port.1.ports.0":  "dynamic",
+		"dependencies.#":          "0",
+		"implicit_applications.0": "ssl",
+	} {
+		if attrs[key] != want {
+			t.Errorf("%s is %q, expected %q", key, attrs[key], want)
+		}
+	}
+
+	// Applications not in the version are not found.
+	attrs = testReadDataSource(t, dataSourceAppIdApplication(), m.meta(), map[string]interface{}{
+		"version": "8509-7158",
+		"name":    "ssh",
+	})
+	if attrs["id"] != "" {
+		t.Errorf("Got id %q for an unknown application", attrs["id"])
+	}
+}
+
+func TestCachedAppIdApplicationsOffline(t *testing.T) {
+	m := newMockApi(t)
+	m.AppIdVersions["8509-7158"] = []string{"ssl", "web-browsing"}
+	meta := m.meta()
+	con := meta.(*providerMeta).client
+
+	// Concurrent callers share a single read.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			apps, err := cachedAppIdApplications(context.Background(), meta, con, "8509-7158")
+			if err != nil || !apps["ssl"] {
+				t.Errorf("Got applications %v, error %v", apps, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	reads := 0
+	for _, req := range m.Requests {
+		if req == "GET /v1/config/appidversions/8509-7158" {
+			reads++
+		}
+	}
+	if reads != 1 {
+		t.Errorf("Applications were read %d times", reads)
+	}
+
+	// Failed reads are not cached.
+	if _, err := cachedAppIdApplications(context.Background(), meta, con, "8510-7160"); err == nil {
+		t.Fatalf("Reading a missing version did not fail")
+	}
+	m.AppIdVersions["8510-7160"] = []string{"ssl"}
+	if apps, err := cachedAppIdApplications(context.Background(), meta, con, "8510-7160"); err != nil || !apps["ssl"] {
+		t.Errorf("Got applications %v, error %v after the failed read", apps, err)
+	}
+}
@@ -0,0 +1,289 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// The STS endpoint to use instead of the regional one, for testing.
+var stsEndpoint string
+
+// authConfig is the credential chain and assume role config that the SDK
+// does not handle itself.  When any of it is set the provider assumes the
+// firewall and rulestack admin roles and gets the JWTs on its own.
+type authConfig struct {
+	Profile                string            `json:"profile"`
+	SharedConfigFiles      []string          `json:"shared-config-files"`
+	SharedCredentialsFiles []string          `json:"shared-credentials-files"`
+	WebIdentityTokenFile   string            `json:"web-identity-token-file"`
+	WebIdentityRoleArn     string            `json:"web-identity-role-arn"`
+	ExternalId             string            `json:"external-id"`
+	RoleSessionName        string            `json:"role-session-name"`
+	SessionDuration        int               `json:"session-duration"`
+	SessionTags            map[string]string `json:"session-tags"`
+}
+
+// loadAuthConfig reads the auth config from the provider config and the
+// environment, then from the JSON config file for anything still unset.
+func loadAuthConfig(d *schema.ResourceData) (authConfig, error) {
+	a := authConfig{
+		Profile:                d.Get("profile").(string),
+		SharedConfigFiles:      toStringSlice(d.Get("shared_config_files")),
+		SharedCredentialsFiles: toStringSlice(d.Get("shared_credentials_files")),
+		WebIdentityTokenFile:   d.Get("web_identity_token_file").(string),
+		WebIdentityRoleArn:     d.Get("web_identity_role_arn").(string),
+		ExternalId:             d.Get("external_id").(string),
+		RoleSessionName:        d.Get("role_session_name").(string),
+		SessionDuration:        d.Get("session_duration").(int),
+	}
+	if tags := d.Get("session_tags").(map[string]interface{}); len(tags) > 0 {
+		a.SessionTags = make(map[string]string, len(tags))
+		for key, val := range tags {
+			a.SessionTags[key] = val.(string)
+		}
+	}
+
+	path := d.Get("json_config_file").(string)
+	if path == "" {
+		return a, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return a, err
+	}
+	var f authConfig
+	if err = json.Unmarshal(data, &f); err != nil {
+		return a, fmt.Errorf("Error parsing %s: %s", path, err)
+	}
+
+	if a.Profile == "" {
+		a.Profile = f.Profile
+	}
+	if len(a.SharedConfigFiles) == 0 {
+		a.SharedConfigFiles = f.SharedConfigFiles
+	}
+	if len(a.SharedCredentialsFiles) == 0 {
+		a.SharedCredentialsFiles = f.SharedCredentialsFiles
+	}
+	if a.WebIdentityTokenFile == "" {
+		a.WebIdentityTokenFile = f.WebIdentityTokenFile
+	}
+	if a.WebIdentityRoleArn == "" {
+		a.WebIdentityRoleArn = f.WebIdentityRoleArn
+	}
+	if a.ExternalId == "" {
+		a.ExternalId = f.ExternalId
+	}
+	if a.RoleSessionName == "" {
+		a.RoleSessionName = f.RoleSessionName
+	}
+	if a.SessionDuration == 0 {
+		a.SessionDuration = f.SessionDuration
+	}
+	if len(a.SessionTags) == 0 {
+		a.SessionTags = f.SessionTags
+	}
+
+	return a, nil
+}
+
+// enabled returns whether the provider gets the JWTs instead of the SDK.
+func (a authConfig) enabled() bool {
+	return a.Profile != "" || len(a.SharedConfigFiles) > 0 || len(a.SharedCredentialsFiles) > 0 ||
+		a.WebIdentityTokenFile != "" || a.WebIdentityRoleArn != "" || a.ExternalId != "" ||
+		a.RoleSessionName != "" || a.SessionDuration != 0 || len(a.SessionTags) > 0
+}
+
+// session returns an AWS session with the credentials that assume the
+// roles: the static keys if given, otherwise the web identity token, the
+// profile and the rest of the default credential chain.
+func (a authConfig) session(region, accessKey, secretKey string) (*session.Session, error) {
+	opts := session.Options{
+		Config: aws.Config{
+			Region: aws.String(region),
+		},
+		Profile:           a.Profile,
+		SharedConfigState: session.SharedConfigEnable,
+	}
+	if stsEndpoint != "" {
+		opts.Config.Endpoint = aws.String(stsEndpoint)
+	}
+	if len(a.SharedCredentialsFiles) > 0 || len(a.SharedConfigFiles) > 0 {
+		opts.SharedConfigFiles = append(append([]string(nil), a.SharedCredentialsFiles...), a.SharedConfigFiles...)
+	}
+	if accessKey != "" {
+		opts.Config.Credentials = credentials.NewStaticCredentials(accessKey, secretKey, "")
+	}
+
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if accessKey == "" && a.WebIdentityTokenFile != "" {
+		arn := a.WebIdentityRoleArn
+		if arn == "" {
+			arn = os.Getenv("AWS_ROLE_ARN")
+		}
+		if arn == "" {
+			return nil, fmt.Errorf("web_identity_role_arn is required with web_identity_token_file")
+		}
+		sess = sess.Copy(&aws.Config{
+			Credentials: stscreds.NewWebIdentityCredentials(sess, arn, a.RoleSessionName, a.WebIdentityTokenFile),
+		})
+	}
+
+	return sess, nil
+}
+
+// assumeRole returns the credentials of the given role, with the session
+// options applied.
+func (a authConfig) assumeRole(sess *session.Session, arn string) *credentials.Credentials {
+	return stscreds.NewCredentials(sess, arn, func(p *stscreds.AssumeRoleProvider) {
+		if a.ExternalId != "" {
+			p.ExternalID = aws.String(a.ExternalId)
+		}
+		if a.RoleSessionName != "" {
+			p.RoleSessionName = a.RoleSessionName
+		}
+		if a.SessionDuration > 0 {
+			p.Duration = time.Duration(a.SessionDuration) * time.Second
+		}
+		keys := make([]string, 0, len(a.SessionTags))
+		for key := range a.SessionTags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			p.Tags = append(p.Tags, &sts.Tag{
+				Key:   aws.String(key),
+				Value: aws.String(a.SessionTags[key]),
+			})
+		}
+	})
+}
+
+// authJwts gets the firewall and rulestack admin JWTs with the credentials
+// of each role.
+type authJwts struct {
+	client    *http.Client
+	baseUrl   string
+	region    string
+	firewall  *credentials.Credentials
+	rulestack *credentials.Credentials
+}
+
+func (a authConfig) jwts(client *http.Client, baseUrl, region, accessKey, secretKey, firewallArn, rulestackArn string) (*authJwts, error) {
+	sess, err := a.session(region, accessKey, secretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &authJwts{
+		client:  client,
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		region:  region,
+	}
+	if firewallArn != "" {
+		j.firewall = a.assumeRole(sess, firewallArn)
+	}
+	if rulestackArn != "" {
+		j.rulestack = a.assumeRole(sess, rulestackArn)
+	}
+
+	return j, nil
+}
+
+// get returns the JWTs of the roles that are set.
+func (j *authJwts) get(ctx context.Context) (string, string, error) {
+	var fw, rs string
+	var err error
+
+	if j.firewall != nil {
+		if fw, err = j.token(ctx, j.firewall, "cloudfirewalladmin"); err != nil {
+			return "", "", err
+		}
+	}
+	if j.rulestack != nil {
+		if rs, err = j.token(ctx, j.rulestack, "cloudrulestackadmin"); err != nil {
+			return "", "", err
+		}
+	}
+
+	return fw, rs, nil
+}
+
+func (j *authJwts) token(ctx context.Context, creds *credentials.Credentials, kind string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.baseUrl+"/v1/mgmt/tokens/"+kind, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err = v4.NewSigner(creds).Sign(req, nil, "execute-api", j.region, time.Now()); err != nil {
+		return "", err
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Error getting %s token: %s: %s", kind, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var ans struct {
+		Response struct {
+			TokenId string
+		}
+	}
+	if err = json.Unmarshal(body, &ans); err != nil {
+		return "", err
+	}
+	if ans.Response.TokenId == "" {
+		return "", fmt.Errorf("Error getting %s token: no token returned", kind)
+	}
+
+	return ans.Response.TokenId, nil
+}
+
+// jwtRefresh returns the func that gets new firewall and rulestack JWTs for
+// the client with the credentials resolved by the provider.
+func (a authConfig) jwtRefresh(con *awsngfw.Client) (func(context.Context) (string, string, error), error) {
+	firewallArn, rulestackArn := con.LfaArn, con.LraArn
+	if firewallArn == "" {
+		firewallArn = con.Arn
+	}
+	if rulestackArn == "" {
+		rulestackArn = con.Arn
+	}
+
+	j, err := a.jwts(con.HttpClient, apiBaseUrl(con), con.Region, con.AccessKey, con.SecretKey, firewallArn, rulestackArn)
+	if err != nil {
+		return nil, err
+	}
+
+	return j.get, nil
+}
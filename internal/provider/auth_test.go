@@ -0,0 +1,242 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// mockSts is a fake STS endpoint that records the requests made to it and
+// returns credentials with an access key ID naming the role assumed.
+type mockSts struct {
+	mu       sync.Mutex
+	requests []url.Values
+	signers  []string
+}
+
+func (m *mockSts) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	m.mu.Lock()
+	m.requests = append(m.requests, r.PostForm)
+	m.signers = append(m.signers, authAccessKey(r))
+	m.mu.Unlock()
+
+	action := r.PostForm.Get("Action")
+	role := r.PostForm.Get("RoleArn")
+	fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>ASIA-%[2]s</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </%[1]sResult>
+</%[1]sResponse>`, action, role[strings.LastIndex(role, "/")+1:])
+}
+
+// request returns the STS request with the given action and role.
+func (m *mockSts) request(t *testing.T, action, role string) (url.Values, string) {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, v := range m.requests {
+		if v.Get("Action") == action && v.Get("RoleArn") == role {
+			return v, m.signers[i]
+		}
+	}
+	t.Fatalf("No %s request for %s, got %v", action, role, m.requests)
+	return nil, ""
+}
+
+// authAccessKey returns the access key ID a request is signed with.
+func authAccessKey(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	i := strings.Index(auth, "Credential=")
+	if i < 0 {
+		return ""
+	}
+	auth = auth[i+len("Credential="):]
+
+	return auth[:strings.Index(auth, "/")]
+}
+
+// testAuth gets JWTs with the given auth config against fake STS and token
+// endpoints, returning the STS requests along with the access key each JWT
+// was requested with.
+func testAuth(t *testing.T, a authConfig, accessKey, secretKey string) (*mockSts, map[string]string) {
+	t.Helper()
+
+	// Ignore the credentials of whoever runs the tests.
+	dir := t.TempDir()
+	for _, key := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	sts := &mockSts{}
+	stsSrv := httptest.NewServer(sts)
+	defer stsSrv.Close()
+	stsEndpoint = stsSrv.URL
+	defer func() { stsEndpoint = "" }()
+
+	var mu sync.Mutex
+	signers := make(map[string]string)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kind := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		mu.Lock()
+		signers[kind] = authAccessKey(r)
+		mu.Unlock()
+		fmt.Fprintf(w, `{"Response": {"TokenId": "jwt-%s"}}`, kind)
+	}))
+	defer api.Close()
+
+	j, err := a.jwts(api.Client(), api.URL, "us-east-1", accessKey, secretKey, "arn:aws:iam::123:role/fw", "arn:aws:iam::123:role/rs")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	fw, rs, err := j.get(context.Background())
+	if err != nil {
+		t.Fatalf("Error getting jwts: %s", err)
+	}
+	if fw != "jwt-cloudfirewalladmin" || rs != "jwt-cloudrulestackadmin" {
+		t.Errorf("Got jwts %q and %q", fw, rs)
+	}
+
+	return sts, signers
+}
+
+func TestAuthAssumeRoleOptions(t *testing.T) {
+	a := authConfig{
+		ExternalId:      "secret-id",
+		RoleSessionName: "terraform",
+		SessionDuration: 1800,
+		SessionTags:     map[string]string{"team": "netsec", "env": "prod"},
+	}
+	sts, signers := testAuth(t, a, "AKID-STATIC", "secret")
+
+	for _, role := range []string{"fw", "rs"} {
+		req, signer := sts.request(t, "AssumeRole", "arn:aws:iam::123:role/"+role)
+		if signer != "AKID-STATIC" {
+			t.Errorf("%s: assumed with %q", role, signer)
+		}
+		for key, want := range map[string]string{
+			"ExternalId":          "secret-id",
+			"RoleSessionName":     "terraform",
+			"DurationSeconds":     "1800",
+			"Tags.member.1.Key":   "env",
+			"Tags.member.1.Value": "prod",
+			"Tags.member.2.Key":   "team",
+			"Tags.member.2.Value": "netsec",
+		} {
+			if v := req.Get(key); v != want {
+				t.Errorf("%s: %s is %q, expected %q", role, key, v, want)
+			}
+		}
+	}
+
+	if signers["cloudfirewalladmin"] != "ASIA-fw" || signers["cloudrulestackadmin"] != "ASIA-rs" {
+		t.Errorf("Tokens were not requested with the role credentials: %v", signers)
+	}
+}
+
+func TestAuthProfile(t *testing.T) {
+	dir := t.TempDir()
+	creds := filepath.Join(dir, "creds")
+	ioutil.WriteFile(creds, []byte("[ci]\naws_access_key_id = AKID-CREDS\naws_secret_access_key = secret\n"), 0600)
+	conf := filepath.Join(dir, "conf")
+	ioutil.WriteFile(conf, []byte("[profile other]\naws_access_key_id = AKID-CONF\naws_secret_access_key = secret\n"), 0600)
+
+	for _, tc := range []struct {
+		profile string
+		want    string
+	}{
+		{"ci", "AKID-CREDS"},
+		{"other", "AKID-CONF"},
+	} {
+		a := authConfig{
+			Profile:                tc.profile,
+			SharedCredentialsFiles: []string{creds},
+			SharedConfigFiles:      []string{conf},
+		}
+		sts, _ := testAuth(t, a, "", "")
+		if _, signer := sts.request(t, "AssumeRole", "arn:aws:iam::123:role/fw"); signer != tc.want {
+			t.Errorf("Profile %q: assumed with %q, expected %q", tc.profile, signer, tc.want)
+		}
+	}
+}
+
+func TestAuthWebIdentity(t *testing.T) {
+	token := filepath.Join(t.TempDir(), "token")
+	ioutil.WriteFile(token, []byte("oidc-token"), 0600)
+
+	a := authConfig{
+		WebIdentityTokenFile: token,
+		WebIdentityRoleArn:   "arn:aws:iam::123:role/ci",
+		RoleSessionName:      "pipeline",
+	}
+	sts, _ := testAuth(t, a, "", "")
+
+	req, _ := sts.request(t, "AssumeRoleWithWebIdentity", "arn:aws:iam::123:role/ci")
+	if v := req.Get("WebIdentityToken"); v != "oidc-token" {
+		t.Errorf("Got web identity token %q", v)
+	}
+	if v := req.Get("RoleSessionName"); v != "pipeline" {
+		t.Errorf("Got session name %q", v)
+	}
+	if _, signer := sts.request(t, "AssumeRole", "arn:aws:iam::123:role/rs"); signer != "ASIA-ci" {
+		t.Errorf("Assumed with %q, expected the web identity credentials", signer)
+	}
+}
+
+func TestLoadAuthConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf.json")
+	ioutil.WriteFile(path, []byte(`{
+		"external-id": "from-json",
+		"profile": "from-json",
+		"session-duration": 3600,
+		"session-tags": {"team": "netsec"},
+		"shared-config-files": ["/json/config"]
+	}`), 0600)
+
+	t.Setenv("CLOUDNGFWAWS_PROFILE", "from-env")
+	t.Setenv("CLOUDNGFWAWS_ROLE_SESSION_NAME", "from-env")
+	d := schema.TestResourceDataRaw(t, providerSchema(), map[string]interface{}{
+		"json_config_file": path,
+		"external_id":      "from-config",
+	})
+
+	a, err := loadAuthConfig(d)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if a.ExternalId != "from-config" || a.Profile != "from-env" || a.RoleSessionName != "from-env" {
+		t.Errorf("Config and environment were not preferred: %#v", a)
+	}
+	if a.SessionDuration != 3600 || a.SessionTags["team"] != "netsec" || len(a.SharedConfigFiles) != 1 {
+		t.Errorf("JSON config was not used: %#v", a)
+	}
+	if !a.enabled() {
+		t.Errorf("Auth config is not enabled")
+	}
+
+	os.Unsetenv("CLOUDNGFWAWS_PROFILE")
+	os.Unsetenv("CLOUDNGFWAWS_ROLE_SESSION_NAME")
+	if a, _ := loadAuthConfig(schema.TestResourceDataRaw(t, providerSchema(), map[string]interface{}{})); a.enabled() {
+		t.Errorf("Auth config is enabled without any settings: %#v", a)
+	}
+}
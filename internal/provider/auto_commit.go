@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"context"
+	"sync"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// autoCommitter tracks the rulestacks that the plan changes, so that the
+// commit rulestack resource commits each of them once per apply.
+//
+// Terraform has no hook that runs once an apply is done, so the commit is
+// left to the commit rulestack resource, which depends on the resources that
+// change the rulestack.  Those resources only mark the rulestack as changed
+// when they are planned, and the commit rulestack resource, planned after
+// them, then plans a commit even though the rulestack has no uncommitted
+// changes yet.  A commit that fails is only reported by the commit rulestack
+// resource, not by the resources whose changes it commits.
+type autoCommitter struct {
+	all   bool
+	names map[string]bool
+
+	mu      sync.Mutex
+	changed map[autoCommitKey]bool
+}
+
+// autoCommitKey is a rulestack, along with the client that manages it.
+type autoCommitKey struct {
+	con  *awsngfw.Client
+	name string
+}
+
+func newAutoCommitter(all bool, names []string) *autoCommitter {
+	c := &autoCommitter{
+		all:     all,
+		names:   make(map[string]bool, len(names)),
+		changed: make(map[autoCommitKey]bool),
+	}
+	for _, name := range names {
+		c.names[name] = true
+	}
+
+	return c
+}
+
+// enabled returns whether the rulestack is auto committed.
+func (c *autoCommitter) enabled(name string) bool {
+	return c != nil && (c.all || c.names[name])
+}
+
+// markChanged records that the plan changes the rulestack.
+func (c *autoCommitter) markChanged(con *awsngfw.Client, name string) {
+	if !c.enabled(name) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.changed[autoCommitKey{con, name}] = true
+}
+
+// isChanged returns whether the plan changes the rulestack.
+func (c *autoCommitter) isChanged(con *awsngfw.Client, name string) bool {
+	if !c.enabled(name) {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.changed[autoCommitKey{con, name}]
+}
+
+// clearChanged records that the planned changes to the rulestack have been
+// committed.
+func (c *autoCommitter) clearChanged(con *awsngfw.Client, name string) {
+	if !c.enabled(name) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.changed, autoCommitKey{con, name})
+}
+
+// customizeDiffAutoCommit returns the CustomizeDiff func of a resource that
+// changes the rulestack named by the given key, marking the rulestack as
+// changed when the resource has any planned changes.
+func customizeDiffAutoCommit(key string) schema.CustomizeDiffFunc {
+	return func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+		c := meta.(*providerMeta).commits
+		if !d.NewValueKnown(key) || !c.enabled(d.Get(key).(string)) {
+			return nil
+		}
+		if d.Id() != "" && len(d.GetChangedKeysPrefix("")) == 0 {
+			return nil
+		}
+
+		con, err := apiClient(ctx, d, meta)
+		if err != nil {
+			return err
+		}
+
+		name := d.Get(key).(string)
+		tflog.Info(
+			ctx, "auto commit rulestack",
+			RulestackName, name,
+		)
+		c.markChanged(con, name)
+
+		return nil
+	}
+}
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+func TestAutoCommitOffline(t *testing.T) {
+	m := newMockApi(t)
+	rs := m.addRulestack("rs")
+	other := m.addRulestack("other")
+	meta := &providerMeta{
+		client:  m.client(),
+		commits: newAutoCommitter(false, []string{"rs"}),
+	}
+	raw := map[string]interface{}{
+		RulestackName:   "rs",
+		"fail_on_error": true,
+	}
+	commit := newTestLifecycle(t, resourceCommitRulestack(), meta)
+	commit.apply(raw)
+	otherRaw := map[string]interface{}{
+		RulestackName: "other",
+	}
+	otherCommit := newTestLifecycle(t, resourceCommitRulestack(), meta)
+	otherCommit.apply(otherRaw)
+
+	// Changes made in parallel only mark the rulestack as changed.
+	names := []string{"pl1", "pl2", "pl3"}
+	lists := make([]*testLifecycle, len(names))
+	results := make([]diag.Diagnostics, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		lists[i] = newTestLifecycle(t, resourcePrefixList(), meta)
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = lists[i].applyDiags(map[string]interface{}{
+				RulestackName: "rs",
+				"name":        name,
+				"prefix_list": []interface{}{"10.0.0.0/8"},
+			})
+		}(i, name)
+	}
+	wg.Wait()
+
+	for i, diags := range results {
+		if diags.HasError() {
+			t.Fatalf("Error applying %s: %s", names[i], diagsToString(diags))
+		}
+	}
+	if rs.Commits != 1 {
+		t.Errorf("Got %d commits before the commit resource", rs.Commits)
+	}
+
+	// The commit resource plans a single commit for all of them.
+	commit.apply(raw)
+	if rs.Commits != 2 || rs.State != "Running" {
+		t.Errorf("Got %d commits, state %q", rs.Commits, rs.State)
+	}
+
+	// Resources without changes do not plan another commit.
+	lists[0].apply(map[string]interface{}{
+		RulestackName: "rs",
+		"name":        "pl1",
+		"prefix_list": []interface{}{"10.0.0.0/8"},
+	})
+	commit.apply(raw)
+	if rs.Commits != 2 {
+		t.Errorf("Got %d commits without changes", rs.Commits)
+	}
+
+	// Other rulestacks are left to their uncommitted state.
+	l := newTestLifecycle(t, resourcePrefixList(), meta)
+	l.apply(map[string]interface{}{
+		RulestackName: "other",
+		"name":        "pl",
+		"prefix_list": []interface{}{"10.0.0.0/8"},
+	})
+	if diags := otherCommit.applyDiags(otherRaw); diags.HasError() {
+		t.Fatalf("Error applying the other commit: %s", diagsToString(diags))
+	}
+	if other.Commits != 1 || other.State != "Uncommitted" {
+		t.Errorf("Other rulestack got %d commits, state %q", other.Commits, other.State)
+	}
+
+	// Commit failures are only returned by the commit resource.
+	rs.ValidationMessages = []string{"rule r1 references missing prefix list"}
+	diags := lists[2].applyDiags(map[string]interface{}{
+		RulestackName: "rs",
+		"name":        "pl3",
+		"description": "fails",
+		"prefix_list": []interface{}{"10.0.0.0/8"},
+	})
+	if len(diags) != 0 {
+		t.Errorf("Change returned diagnostics: %s", diagsToString(diags))
+	}
+	diags = commit.applyDiags(raw)
+	if !diags.HasError() || !strings.Contains(diagsToString(diags), "missing prefix list") {
+		t.Errorf("Commit failure was not returned: %s", diagsToString(diags))
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/object/certificate"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -25,7 +24,11 @@ func dataSourceCertificate() *schema.Resource {
 }
 
 func readCertificateDataSource(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := certificate.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := certificate.NewClient(con)
 
 	style := d.Get(ConfigTypeName).(string)
 	d.Set(ConfigTypeName, style)
@@ -77,6 +80,85 @@ func readCertificateDataSource(ctx context.Context, d *schema.ResourceData, meta
 	return nil
 }
 
+// Data source (list).
+func dataSourceCertificates() *schema.Resource {
+	return &schema.Resource{
+		Description: "Data source for retrieving every certificate in a rulestack.",
+
+		ReadContext: readCertificates,
+
+		Schema: objectListSchema(
+			"certificates", "The certificates.",
+			certificateSchema(false, []string{ConfigTypeName, RulestackName}),
+		),
+	}
+}
+
+func readCertificates(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := certificate.NewClient(con)
+
+	style := d.Get(ConfigTypeName).(string)
+	d.Set(ConfigTypeName, style)
+
+	stack := d.Get(RulestackName).(string)
+
+	tflog.Info(
+		ctx, "read certificates",
+		"ds", true,
+		ConfigTypeName, style,
+		RulestackName, stack,
+	)
+
+	list, err := listCertificateNames(ctx, svc, stack, style)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	names := make([]string, 0, len(list))
+	items := make([]interface{}, 0, len(list))
+	for _, name := range list {
+		req := certificate.ReadInput{
+			Rulestack: stack,
+			Name:      name,
+		}
+		switch style {
+		case CandidateConfig:
+			req.Candidate = true
+		case RunningConfig:
+			req.Running = true
+		}
+
+		res, err := svc.Read(ctx, req)
+		if err != nil {
+			if isObjectNotFound(err) {
+				continue
+			}
+			return diag.FromErr(err)
+		}
+
+		info := res.Response.Candidate
+		if style == RunningConfig {
+			info = res.Response.Running
+		}
+		if info == nil {
+			continue
+		}
+
+		names = append(names, name)
+		items = append(items, dumpCertificate(name, *info))
+	}
+
+	d.SetId(configTypeId(style, stack))
+	d.Set("names", names)
+	d.Set("certificates", items)
+
+	return nil
+}
+
 // Resource.
 func resourceCertificate() *schema.Resource {
 	return &schema.Resource{
@@ -87,16 +169,22 @@ func resourceCertificate() *schema.Resource {
 		UpdateContext: updateCertificate,
 		DeleteContext: deleteCertificate,
 
+		CustomizeDiff: customizeDiffAutoCommit(RulestackName),
+
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
 
-		Schema: certificateSchema(true, []string{ConfigTypeName}),
+		Schema: runningDiffSchema(certificateSchema(true, []string{ConfigTypeName})),
 	}
 }
 
 func createCertificate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := certificate.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := certificate.NewClient(con)
 	o := loadCertificate(d)
 	tflog.Info(
 		ctx, "create certificate",
@@ -114,39 +202,51 @@ func createCertificate(ctx context.Context, d *schema.ResourceData, meta interfa
 }
 
 func readCertificate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := certificate.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := certificate.NewClient(con)
 	stack, name, err := parseCertificateId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
 	}
 
-	req := certificate.ReadInput{
-		Rulestack: stack,
-		Name:      name,
-		Candidate: true,
-	}
 	tflog.Info(
 		ctx, "read certificate",
-		RulestackName, req.Rulestack,
+		RulestackName, stack,
 		"name", name,
 	)
 
-	res, err := svc.Read(ctx, req)
+	candidate, running, err := readCertificateConfigs(ctx, svc, stack, name)
 	if err != nil {
 		if isObjectNotFound(err) {
 			d.SetId("")
 			return nil
 		}
 		return diag.FromErr(err)
+	} else if candidate == nil {
+		d.SetId("")
+		return nil
 	}
 
-	saveCertificate(d, stack, name, *res.Response.Candidate)
+	saveCertificate(d, stack, name, *candidate)
+
+	var rdump map[string]interface{}
+	if running != nil {
+		rdump = dumpCertificate(name, *running)
+	}
+	saveRunningDiff(d, dumpCertificate(name, *candidate), rdump)
 
 	return nil
 }
 
 func updateCertificate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := certificate.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := certificate.NewClient(con)
 	o := loadCertificate(d)
 	tflog.Info(
 		ctx, "update certificate",
@@ -162,7 +262,11 @@ func updateCertificate(ctx context.Context, d *schema.ResourceData, meta interfa
 }
 
 func deleteCertificate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := certificate.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := certificate.NewClient(con)
 	stack, name, err := parseCertificateId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
@@ -182,6 +286,52 @@ func deleteCertificate(ctx context.Context, d *schema.ResourceData, meta interfa
 	return nil
 }
 
+// listCertificateNames returns the name of every certificate in the given
+// config.
+func listCertificateNames(ctx context.Context, svc *certificate.Client, stack, style string) ([]string, error) {
+	return listAll(func(token string) ([]string, string, error) {
+		input := certificate.ListInput{
+			Rulestack:  stack,
+			NextToken:  token,
+			MaxResults: 100,
+		}
+		switch style {
+		case CandidateConfig:
+			input.Candidate = true
+		case RunningConfig:
+			input.Running = true
+		}
+
+		res, err := svc.List(ctx, input)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if style == RunningConfig {
+			return res.Response.Running, res.Response.NextToken, nil
+		}
+		return res.Response.Candidate, res.Response.NextToken, nil
+	})
+}
+
+// readCertificateConfigs returns the candidate and running config of a
+// certificate, either of which is nil if it is absent from that config.
+func readCertificateConfigs(ctx context.Context, svc *certificate.Client, stack, name string) (*certificate.Info, *certificate.Info, error) {
+	req := certificate.ReadInput{
+		Rulestack: stack,
+		Name:      name,
+		Candidate: true,
+		Running:   true,
+	}
+
+	res, err := svc.Read(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res.Response.Candidate, res.Response.Running, nil
+}
+
 // Schema handling.
 func certificateSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 	ans := map[string]*schema.Schema{
@@ -246,12 +396,20 @@ func loadCertificate(d *schema.ResourceData) certificate.Info {
 
 func saveCertificate(d *schema.ResourceData, stack, name string, o certificate.Info) {
 	d.Set(RulestackName, stack)
-	d.Set("name", name)
-	d.Set("description", o.Description)
-	d.Set("signer_arn", o.SignerArn)
-	d.Set("self_signed", o.SelfSigned)
-	d.Set("audit_comment", o.AuditComment)
-	d.Set("update_token", o.UpdateToken)
+	for key, value := range dumpCertificate(name, o) {
+		d.Set(key, value)
+	}
+}
+
+func dumpCertificate(name string, o certificate.Info) map[string]interface{} {
+	return map[string]interface{}{
+		"name":          name,
+		"description":   o.Description,
+		"signer_arn":    o.SignerArn,
+		"self_signed":   o.SelfSigned,
+		"audit_comment": o.AuditComment,
+		"update_token":  o.UpdateToken,
+	}
 }
 
 // Id functions.
@@ -0,0 +1,207 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/api"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// providerMeta is the configured provider, passed to every resource and
+// data source as meta.
+type providerMeta struct {
+	client  *awsngfw.Client
+	commits *autoCommitter
+
+	// newClient returns an authenticated client for a region / role
+	// override.
+	newClient func(context.Context, clientKey) (*awsngfw.Client, error)
+
+	mu      sync.Mutex
+	clients map[clientKey]*awsngfw.Client
+
+	// The resource types seen managing the tags of each firewall.
+	ngfwTags map[ngfwTagKey]map[string]bool
+
+	// The App-ID version each resource type sets on each firewall.
+	appIdOwners map[ngfwTagKey]map[string]string
+
+	// The App-ID versions and applications read so far, or being read.
+	appIdMu    sync.Mutex
+	appIdReads map[interface{}]*appIdRead
+}
+
+// clientKey is a region / role override of the provider config.
+type clientKey struct {
+	region  string
+	roleArn string
+}
+
+// clientFor returns the client for the given override, authenticating a new
+// one the first time each override is used.
+func (p *providerMeta) clientFor(ctx context.Context, key clientKey) (*awsngfw.Client, error) {
+	if key == (clientKey{}) {
+		return p.client, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if con := p.clients[key]; con != nil {
+		return con, nil
+	}
+
+	tflog.Info(
+		ctx, "new api client",
+		"region", key.region,
+		"role_arn", key.roleArn,
+	)
+
+	con, err := p.newClient(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.clients == nil {
+		p.clients = make(map[clientKey]*awsngfw.Client)
+	}
+	p.clients[key] = con
+
+	return con, nil
+}
+
+// regionHost returns the API host for a region override of the provider's
+// host.  Only the regional hosts can be overridden, as a custom host is only
+// known to serve its own region.
+func regionHost(host, region string) (string, error) {
+	if host == "" {
+		return fmt.Sprintf("api.%s.aws.cloudngfw.com", region), nil
+	}
+
+	tok := strings.Split(host, ".")
+	if len(tok) == 5 && tok[0] == "api" && strings.Join(tok[2:], ".") == "aws.cloudngfw.com" {
+		tok[1] = region
+		return strings.Join(tok, "."), nil
+	}
+
+	return "", fmt.Errorf("The region can not be overridden to %q with the provider host %q, which is not a regional API host", region, host)
+}
+
+// apiBaseUrl returns the URL the client sends its API calls to.
+func apiBaseUrl(con *awsngfw.Client) string {
+	host := con.Host
+	if host == "" {
+		host = fmt.Sprintf("api.%s.aws.cloudngfw.com", con.Region)
+	}
+	protocol := con.Protocol
+	if protocol == "" {
+		protocol = "https"
+	}
+
+	return protocol + "://" + host
+}
+
+// apiCall performs an API call that the SDK has no func for, with the given
+// JWT and the client's HTTP client, so that it is retried and has its JWT
+// refreshed like any other.  The output, if given, is unmarshaled from the
+// "Response" of the API's answer.  API errors are returned as an
+// *api.Status, same as the SDK does.
+func apiCall(ctx context.Context, con *awsngfw.Client, method, path, jwt string, input, output interface{}) error {
+	var body io.Reader
+	if input != nil {
+		data, err := json.Marshal(input)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, apiBaseUrl(con)+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(jwtHeader, jwt)
+	req.Header.Set("Content-Type", "application/json")
+	if con.Agent != "" {
+		req.Header.Set("User-Agent", con.Agent)
+	}
+	for key, val := range con.Headers {
+		req.Header.Set(key, val)
+	}
+
+	client := con.HttpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var ans struct {
+		Response json.RawMessage `json:"Response"`
+		Status   *api.Status     `json:"ResponseStatus"`
+	}
+	if err = json.Unmarshal(data, &ans); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return err
+	}
+	if ans.Status != nil && ans.Status.ErrorCode != 0 {
+		return ans.Status
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+
+	if output == nil || len(ans.Response) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(ans.Response, output)
+}
+
+// apiClient returns the client for the resource or data source, honoring
+// its region and role_arn overrides.
+func apiClient(ctx context.Context, d interface{ Get(string) interface{} }, meta interface{}) (*awsngfw.Client, error) {
+	var key clientKey
+	key.region, _ = d.Get("region").(string)
+	key.roleArn, _ = d.Get("role_arn").(string)
+
+	return meta.(*providerMeta).clientFor(ctx, key)
+}
+
+// addClientOverrideSchema adds the region and role_arn overrides to the
+// schema of a resource or data source.
+func addClientOverrideSchema(r *schema.Resource, isResource bool) {
+	r.Schema["region"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Optional:    true,
+		ForceNew:    isResource,
+		Description: "The AWS region to use instead of the provider's `region`.",
+	}
+	r.Schema["role_arn"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Optional:    true,
+		ForceNew:    isResource && r.UpdateContext == nil,
+		Description: "The ARN of the role to assume, for both firewall and rulestack admin permissions, instead of the provider's role ARNs.",
+	}
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestApiClient(t *testing.T) {
+	base := &awsngfw.Client{Region: "us-east-1"}
+	var made []clientKey
+	meta := &providerMeta{
+		client: base,
+		newClient: func(ctx context.Context, key clientKey) (*awsngfw.Client, error) {
+			made = append(made, key)
+			return &awsngfw.Client{Region: key.region, Arn: key.roleArn}, nil
+		},
+	}
+
+	r := &schema.Resource{
+		Schema:        map[string]*schema.Schema{},
+		UpdateContext: updateRulestack,
+	}
+	addClientOverrideSchema(r, true)
+
+	get := func(raw map[string]interface{}) *awsngfw.Client {
+		t.Helper()
+		con, err := apiClient(context.Background(), schema.TestResourceDataRaw(t, r.Schema, raw), meta)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		return con
+	}
+
+	if con := get(map[string]interface{}{}); con != base {
+		t.Errorf("Got a new client without overrides")
+	}
+
+	eu := get(map[string]interface{}{"region": "eu-west-1"})
+	if eu.Region != "eu-west-1" {
+		t.Errorf("Got region %q", eu.Region)
+	}
+	if con := get(map[string]interface{}{"region": "eu-west-1"}); con != eu {
+		t.Errorf("Client for the same region was not reused")
+	}
+
+	role := get(map[string]interface{}{
+		"region":   "eu-west-1",
+		"role_arn": "arn:aws:iam::123456789:role/Admin",
+	})
+	if role == eu || role.Arn != "arn:aws:iam::123456789:role/Admin" {
+		t.Errorf("Role override did not get its own client")
+	}
+
+	if len(made) != 2 {
+		t.Errorf("Made %d clients, expected 2", len(made))
+	}
+}
+
+func TestRegionHost(t *testing.T) {
+	for _, tc := range []struct {
+		host string
+		want string
+		err  bool
+	}{
+		{"", "api.eu-west-1.aws.cloudngfw.com", false},
+		{"api.us-east-1.aws.cloudngfw.com", "api.eu-west-1.aws.cloudngfw.com", false},
+		{"ngfw.example.com", "", true},
+	} {
+		got, err := regionHost(tc.host, "eu-west-1")
+		if (err != nil) != tc.err || got != tc.want {
+			t.Errorf("%q: got %q, %v", tc.host, got, err)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
@@ -16,6 +19,7 @@ import (
 // Resource.
 func resourceCommitRulestack() *schema.Resource {
 	s := "Running"
+	rv := []string{revertNever, revertCandidate}
 
 	return &schema.Resource{
 		Description: "Resource for committing the rulestack config.",
@@ -25,6 +29,8 @@ func resourceCommitRulestack() *schema.Resource {
 		UpdateContext: createUpdateCommitRulestack,
 		DeleteContext: deleteCommitRulestack,
 
+		CustomizeDiff: customizeDiffCommitRulestack,
+
 		Timeouts: &schema.ResourceTimeout{
 			Create: schema.DefaultTimeout(15 * time.Minute),
 			Update: schema.DefaultTimeout(15 * time.Minute),
@@ -39,6 +45,29 @@ func resourceCommitRulestack() *schema.Resource {
 				Default:      s,
 				ValidateFunc: validation.StringInSlice([]string{s}, false),
 			},
+			"fail_on_error": failOnErrorSchema(),
+			"on_failure": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  addStringInSliceValidation("What to do with the candidate config if the commit or validation does not succeed. Setting this to `revert` discards the uncommitted changes, restoring the candidate config to the running config, and always returns a warning saying so.", rv),
+				Default:      revertNever,
+				ValidateFunc: validation.StringInSlice(rv, false),
+			},
+			"on_destroy": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  addStringInSliceValidation("What to do with the candidate config when this resource is destroyed. Setting this to `revert` discards any uncommitted changes.", rv),
+				Default:      revertNever,
+				ValidateFunc: validation.StringInSlice(rv, false),
+			},
+			"triggers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Description: "Arbitrary values that cause the rulestack to be committed again when they change.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 			"commit_status": {
 				Type:        schema.TypeString,
 				Computed:    true,
@@ -70,46 +99,60 @@ func resourceCommitRulestack() *schema.Resource {
 }
 
 func createUpdateCommitRulestack(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := stack.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := stack.NewClient(con)
 	name := d.Get(RulestackName).(string)
-	pending := "Pending"
 
 	tflog.Info(
 		ctx, "commit rulestack",
 		RulestackName, name,
 	)
 
-	// Perform the commit.
-	if err := svc.Commit(ctx, name); err != nil {
-		return diag.FromErr(err)
+	revert := d.Get("on_failure").(string) == revertCandidate
+	ans, err := commitRulestack(ctx, svc, name)
+	meta.(*providerMeta).commits.clearChanged(con, name)
+	if err != nil {
+		diags := diag.FromErr(err)
+		if revert {
+			if err = revertRulestack(ctx, con, name); err != nil {
+				diags = append(diags, diag.FromErr(err)...)
+			} else {
+				diags = append(diags, revertedDiagnostic(name))
+			}
+		}
+		return diags
 	}
 
-	// Wait until the status is not Pending.
-	for {
-		tflog.Info(
-			ctx, "getting commit status",
-			RulestackName, name,
-		)
-
-		ans, err := svc.CommitStatus(ctx, name)
-		if err != nil {
+	var diags diag.Diagnostics
+	if ans.Response.CommitStatus != commitSuccess && revert {
+		if err = revertRulestack(ctx, con, name); err != nil {
 			return diag.FromErr(err)
 		}
+		diags = append(diags, revertedDiagnostic(name))
+	}
 
-		if ans.Response.CommitStatus != pending {
-			break
-		}
+	d.SetId(name)
 
-		time.Sleep(1 * time.Second)
+	if rd := readCommitRulestack(ctx, d, meta); rd.HasError() {
+		return append(diags, rd...)
 	}
 
-	d.SetId(name)
+	if d.Get("fail_on_error").(bool) {
+		diags = append(commitStatusDiagnostics(name, ans, true), diags...)
+	}
 
-	return readCommitRulestack(ctx, d, meta)
+	return diags
 }
 
 func readCommitRulestack(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := stack.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := stack.NewClient(con)
 	name := d.Id()
 	req := stack.ReadInput{
 		Name: name,
@@ -141,6 +184,178 @@ func readCommitRulestack(ctx context.Context, d *schema.ResourceData, meta inter
 }
 
 func deleteCommitRulestack(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	if d.Get("on_destroy").(string) == revertCandidate {
+		con, err := apiClient(ctx, d, meta)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if err := revertRulestack(ctx, con, d.Id()); err != nil && !isObjectNotFound(err) {
+			return diag.FromErr(err)
+		}
+	}
+
 	d.SetId("")
 	return nil
 }
+
+// customizeDiffCommitRulestack plans another commit if the rulestack has
+// uncommitted changes.
+func customizeDiffCommitRulestack(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || d.HasChange(RulestackName) || !d.NewValueKnown(RulestackName) {
+		return nil
+	}
+
+	name := d.Get(RulestackName).(string)
+	if state, _ := d.GetChange("state"); state.(string) != "Running" {
+		tflog.Info(
+			ctx, "rulestack needs commit",
+			RulestackName, name,
+			"state", state,
+		)
+		return d.SetNewComputed("commit_status")
+	}
+
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return err
+	}
+
+	// Resources planned before this one change an auto committed rulestack.
+	if meta.(*providerMeta).commits.isChanged(con, name) {
+		tflog.Info(
+			ctx, "rulestack needs commit",
+			RulestackName, name,
+			"auto_commit", true,
+		)
+		return d.SetNewComputed("commit_status")
+	}
+
+	// Changes to the candidate config take the rulestack out of the Running
+	// state, so the candidate and running configs only need comparing when
+	// the last commit did not succeed or its status is unknown.
+	if status, _ := d.GetChange("commit_status"); status.(string) == commitSuccess {
+		return nil
+	}
+
+	changes, err := rulestackChanges(ctx, con, name)
+	if err != nil {
+		if isObjectNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if len(changes) > 0 {
+		tflog.Info(
+			ctx, "rulestack needs commit",
+			RulestackName, name,
+			"changes", len(changes),
+		)
+		return d.SetNewComputed("commit_status")
+	}
+
+	return nil
+}
+
+// Commit / validation status handling.
+const commitSuccess = "Success"
+
+// Valid values for on_failure and on_destroy.
+const (
+	revertNever     = "none"
+	revertCandidate = "revert"
+)
+
+// commitRulestack commits the rulestack and waits for the commit to finish.
+func commitRulestack(ctx context.Context, svc *stack.Client, name string) (stack.CommitStatus, error) {
+	var ans stack.CommitStatus
+
+	// Perform the commit.
+	if err := svc.Commit(ctx, name); err != nil {
+		return ans, err
+	}
+
+	// Wait until the status is not Pending.
+	_, err := pollStatus(ctx, fmt.Sprintf("rulestack %q commit", name), func() (string, bool, error) {
+		var err error
+		ans, err = svc.CommitStatus(ctx, name)
+		if err != nil {
+			return "", false, err
+		}
+
+		return ans.Response.CommitStatus, ans.Response.CommitStatus != "Pending", nil
+	})
+
+	return ans, err
+}
+
+// revertRulestack discards the uncommitted changes to the rulestack.  The
+// SDK has no func for this, so the API is called directly.
+func revertRulestack(ctx context.Context, con *awsngfw.Client, name string) error {
+	tflog.Info(
+		ctx, "revert rulestack",
+		RulestackName, name,
+	)
+
+	path := fmt.Sprintf("/v1/config/rulestacks/%s/revert", url.PathEscape(name))
+	return apiCall(ctx, con, http.MethodPost, path, con.RulestackJwt, nil, nil)
+}
+
+// revertedDiagnostic is the warning that the candidate config was reverted,
+// which is returned even when fail_on_error is unset, as the changes made
+// by other resources were discarded.
+func revertedDiagnostic(name string) diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  fmt.Sprintf("Rulestack %q candidate config was reverted", name),
+		Detail:   "The commit did not succeed, so the uncommitted changes were discarded as on_failure is \"revert\". The resources that made those changes will plan to make them again.",
+	}
+}
+
+func failOnErrorSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Description: "Return an error for each commit or validation message if the commit or validation does not succeed.",
+		Default:     true,
+	}
+}
+
+func commitStatusDiagnostics(name string, cs stack.CommitStatus, checkCommit bool) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if v := cs.Response.ValidationStatus; v != "" && v != commitSuccess {
+		diags = append(diags, statusDiagnostics(
+			fmt.Sprintf("Rulestack %q validation status is %s", name, v),
+			cs.Response.ValidationMessages,
+		)...)
+	}
+
+	if v := cs.Response.CommitStatus; checkCommit && v != "" && v != commitSuccess {
+		diags = append(diags, statusDiagnostics(
+			fmt.Sprintf("Rulestack %q commit status is %s", name, v),
+			cs.Response.CommitMessages,
+		)...)
+	}
+
+	return diags
+}
+
+func statusDiagnostics(summary string, msgs []string) diag.Diagnostics {
+	if len(msgs) == 0 {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  summary,
+		}}
+	}
+
+	diags := make(diag.Diagnostics, 0, len(msgs))
+	for _, msg := range msgs {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  summary,
+			Detail:   msg,
+		})
+	}
+
+	return diags
+}
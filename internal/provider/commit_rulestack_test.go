@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// Resource.
+func TestResourceCommitRulestackOffline(t *testing.T) {
+	m := newMockApi(t)
+	rs := m.addRulestack("rs")
+	rs.State = "Uncommitted"
+	rs.Candidate["Description"] = "pending change"
+	l := newTestLifecycle(t, resourceCommitRulestack(), m.meta())
+
+	l.apply(map[string]interface{}{
+		RulestackName: "rs",
+	})
+	l.check(map[string]string{
+		"state":             "Running",
+		"commit_status":     "Success",
+		"validation_status": "Success",
+	})
+	if desc := m.Rulestack("rs").Running["Description"]; desc != "pending change" {
+		t.Errorf("Running description is %v after the commit", desc)
+	}
+}
+
+func TestResourceCommitRulestackRecommitOffline(t *testing.T) {
+	m := newMockApi(t)
+	rs := m.addRulestack("rs")
+	l := newTestLifecycle(t, resourceCommitRulestack(), m.meta())
+	raw := map[string]interface{}{
+		RulestackName: "rs",
+	}
+
+	l.apply(raw)
+	if rs.Commits != 1 {
+		t.Fatalf("Got %d commits after create", rs.Commits)
+	}
+
+	// Uncommitted changes plan another commit.
+	pl := newTestLifecycle(t, resourcePrefixList(), m.meta())
+	pl.apply(map[string]interface{}{
+		RulestackName: "rs",
+		"name":        "pl",
+		"prefix_list": []interface{}{"10.0.0.0/8"},
+	})
+	l.apply(raw)
+	if rs.Commits != 2 {
+		t.Errorf("Got %d commits after a prefix list change", rs.Commits)
+	}
+	if rs.Objects["prefixlists"]["pl"].Running == nil {
+		t.Errorf("Prefix list was not committed")
+	}
+
+	// A committed rulestack is not compared with its running config.
+	m.Requests = nil
+	l.apply(raw)
+	for _, req := range m.Requests {
+		if strings.Contains(req, "/prefixlists") || strings.Contains(req, "/rulelists") {
+			t.Errorf("Plan of a committed rulestack made request %s", req)
+		}
+	}
+
+	// Unless the last commit failed.
+	rs.Objects["prefixlists"]["pl"].Candidate["Description"] = "uncommitted"
+	rs.CommitStatus = "Failed"
+	l.apply(raw)
+	if rs.Commits != 3 {
+		t.Errorf("Got %d commits after a failed commit", rs.Commits)
+	}
+
+	// So does a change to the triggers.
+	raw["triggers"] = map[string]interface{}{"rules": "v2"}
+	l.apply(raw)
+	if rs.Commits != 4 {
+		t.Errorf("Got %d commits after a triggers change", rs.Commits)
+	}
+}
+
+func TestResourceCommitRulestackFailOnError(t *testing.T) {
+	for _, failOnError := range []bool{true, false} {
+		m := newMockApi(t)
+		rs := m.addRulestack("rs")
+		rs.ValidationMessages = []string{"rule r1 references missing prefix list"}
+		l := newTestLifecycle(t, resourceCommitRulestack(), m.meta())
+
+		diags := l.applyDiags(map[string]interface{}{
+			RulestackName:   "rs",
+			"fail_on_error": failOnError,
+		})
+
+		if diags.HasError() != failOnError {
+			t.Errorf("fail_on_error=%t: got errors: %s", failOnError, diagsToString(diags))
+		}
+		if failOnError && !strings.Contains(diagsToString(diags), "missing prefix list") {
+			t.Errorf("Validation message is not in the errors: %s", diagsToString(diags))
+		}
+		if l.get("commit_status") != "Failed" {
+			t.Errorf("fail_on_error=%t: commit status is %q", failOnError, l.get("commit_status"))
+		}
+	}
+}
+
+func TestResourceCommitRulestackRevertOffline(t *testing.T) {
+	m := newMockApi(t)
+	rs := m.addRulestack("rs")
+	pl := newTestLifecycle(t, resourcePrefixList(), m.meta())
+	pl.apply(map[string]interface{}{
+		RulestackName: "rs",
+		"name":        "pl",
+		"prefix_list": []interface{}{"10.0.0.0/8"},
+	})
+
+	// A failed commit discards the uncommitted changes.
+	rs.ValidationMessages = []string{"rule r1 references missing prefix list"}
+	l := newTestLifecycle(t, resourceCommitRulestack(), m.meta())
+	diags := l.applyDiags(map[string]interface{}{
+		RulestackName: "rs",
+		"on_failure":  "revert",
+		"on_destroy":  "revert",
+	})
+	if !diags.HasError() {
+		t.Fatalf("Failed commit did not return an error")
+	}
+	if !strings.Contains(diagsToString(diags), "candidate config was reverted") {
+		t.Errorf("Revert was not reported: %s", diagsToString(diags))
+	}
+	if _, ok := rs.Objects["prefixlists"]["pl"]; ok || rs.State != "Running" {
+		t.Errorf("Candidate config was not reverted, state %q", rs.State)
+	}
+	if !strings.Contains(strings.Join(m.Requests, "\n"), "POST /v1/config/rulestacks/rs/revert") {
+		t.Errorf("Revert was not requested: %v", m.Requests)
+	}
+
+	// Without fail_on_error the revert is still reported, as a warning.
+	pl.apply(map[string]interface{}{
+		RulestackName: "rs",
+		"name":        "pl",
+		"prefix_list": []interface{}{"10.0.0.0/8"},
+	})
+	quiet := newTestLifecycle(t, resourceCommitRulestack(), m.meta())
+	diags = quiet.applyDiags(map[string]interface{}{
+		RulestackName:   "rs",
+		"on_failure":    "revert",
+		"fail_on_error": false,
+	})
+	if diags.HasError() || len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Errorf("Revert without fail_on_error: %s", diagsToString(diags))
+	}
+	if _, ok := rs.Objects["prefixlists"]["pl"]; ok {
+		t.Errorf("Candidate config was not reverted without fail_on_error")
+	}
+
+	// So does destroying the resource.
+	rs.ValidationMessages = nil
+	l.apply(map[string]interface{}{
+		RulestackName: "rs",
+		"on_failure":  "revert",
+		"on_destroy":  "revert",
+	})
+	pl.apply(map[string]interface{}{
+		RulestackName: "rs",
+		"name":        "pl",
+		"prefix_list": []interface{}{"10.0.0.0/8"},
+	})
+	l.destroy()
+	if _, ok := rs.Objects["prefixlists"]["pl"]; ok || rs.State != "Running" {
+		t.Errorf("Candidate config was not reverted on destroy, state %q", rs.State)
+	}
+}
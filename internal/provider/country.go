@@ -2,10 +2,11 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/country"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -32,6 +33,8 @@ func dataSourceCountry() *schema.Resource {
 				Optional:    true,
 				Description: "Pagination token.",
 			},
+			"all_pages":         allPagesSchema(),
+			"max_total_results": maxTotalResultsSchema(),
 			"next_token": {
 				Type:        schema.TypeString,
 				Computed:    true,
@@ -64,28 +67,452 @@ func readCountry(ctx context.Context, d *schema.ResourceData, meta interface{})
 		"token", input.NextToken,
 	)
 
-	svc := country.NewClient(meta.(*awsngfw.Client))
-
-	ans, err := svc.List(ctx, input)
+	con, err := apiClient(ctx, d, meta)
 	if err != nil {
 		return diag.FromErr(err)
 	}
-
-	d.SetId(strings.Join(
-		[]string{strconv.Itoa(input.MaxResults), input.NextToken},
-		IdSeparator,
-	))
+	svc := country.NewClient(con)
 
 	var codes map[string]interface{}
-	if ans.Response != nil && len(ans.Response.Countries) > 0 {
-		codes = make(map[string]interface{})
+	next, err := readPages(input.NextToken, d.Get("all_pages").(bool), d.Get("max_total_results").(int), func(token string) (int, string, error) {
+		input.NextToken = token
+		ans, err := svc.List(ctx, input)
+		if err != nil {
+			return 0, "", err
+		}
+
+		if ans.Response == nil {
+			return 0, "", nil
+		}
+
+		if len(ans.Response.Countries) > 0 && codes == nil {
+			codes = make(map[string]interface{})
+		}
 		for _, x := range ans.Response.Countries {
 			codes[x.Code] = x.Description
 		}
+
+		return len(ans.Response.Countries), ans.Response.NextToken, nil
+	})
+	if err != nil {
+		return diag.FromErr(err)
 	}
 
-	d.Set("next_token", ans.Response.NextToken)
+	d.SetId(strings.Join(
+		[]string{strconv.Itoa(input.MaxResults), d.Get("token").(string)},
+		IdSeparator,
+	))
+
+	d.Set("next_token", next)
 	d.Set("codes", codes)
 
 	return nil
 }
+
+// Country code handling.
+
+// countryCodes are the ISO 3166 alpha-2 country codes and their names.
+var countryCodes = map[string]string{
+	"AD": "Andorra",
+	"AE": "United Arab Emirates",
+	"AF": "Afghanistan",
+	"AG": "Antigua and Barbuda",
+	"AI": "Anguilla",
+	"AL": "Albania",
+	"AM": "Armenia",
+	"AO": "Angola",
+	"AQ": "Antarctica",
+	"AR": "Argentina",
+	"AS": "American Samoa",
+	"AT": "Austria",
+	"AU": "Australia",
+	"AW": "Aruba",
+	"AX": "Åland Islands",
+	"AZ": "Azerbaijan",
+	"BA": "Bosnia and Herzegovina",
+	"BB": "Barbados",
+	"BD": "Bangladesh",
+	"BE": "Belgium",
+	"BF": "Burkina Faso",
+	"BG": "Bulgaria",
+	"BH": "Bahrain",
+	"BI": "Burundi",
+	"BJ": "Benin",
+	"BL": "Saint Barthélemy",
+	"BM": "Bermuda",
+	"BN": "Brunei Darussalam",
+	"BO": "Bolivia",
+	"BQ": "Bonaire, Sint Eustatius and Saba",
+	"BR": "Brazil",
+	"BS": "Bahamas",
+	"BT": "Bhutan",
+	"BV": "Bouvet Island",
+	"BW": "Botswana",
+	"BY": "Belarus",
+	"BZ": "Belize",
+	"CA": "Canada",
+	"CC": "Cocos (Keeling) Islands",
+	"CD": "Democratic Republic of the Congo",
+	"CF": "Central African Republic",
+	"CG": "Congo",
+	"CH": "Switzerland",
+	"CI": "Côte d'Ivoire",
+	"CK": "Cook Islands",
+	"CL": "Chile",
+	"CM": "Cameroon",
+	"CN": "China",
+	"CO": "Colombia",
+	"CR": "Costa Rica",
+	"CU": "Cuba",
+	"CV": "Cabo Verde",
+	"CW": "Curaçao",
+	"CX": "Christmas Island",
+	"CY": "Cyprus",
+	"CZ": "Czechia",
+	"DE": "Germany",
+	"DJ": "Djibouti",
+	"DK": "Denmark",
+	"DM": "Dominica",
+	"DO": "Dominican Republic",
+	"DZ": "Algeria",
+	"EC": "Ecuador",
+	"EE": "Estonia",
+	"EG": "Egypt",
+	"EH": "Western Sahara",
+	"ER": "Eritrea",
+	"ES": "Spain",
+	"ET": "Ethiopia",
+	"FI": "Finland",
+	"FJ": "Fiji",
+	"FK": "Falkland Islands (Malvinas)",
+	"FM": "Micronesia",
+	"FO": "Faroe Islands",
+	"FR": "France",
+	"GA": "Gabon",
+	"GB": "United Kingdom",
+	"GD": "Grenada",
+	"GE": "Georgia",
+	"GF": "French Guiana",
+	"GG": "Guernsey",
+	"GH": "Ghana",
+	"GI": "Gibraltar",
+	"GL": "Greenland",
+	"GM": "Gambia",
+	"GN": "Guinea",
+	"GP": "Guadeloupe",
+	"GQ": "Equatorial Guinea",
+	"GR": "Greece",
+	"GS": "South Georgia and the South Sandwich Islands",
+	"GT": "Guatemala",
+	"GU": "Guam",
+	"GW": "Guinea-Bissau",
+	"GY": "Guyana",
+	"HK": "Hong Kong",
+	"HM": "Heard Island and McDonald Islands",
+	"HN": "Honduras",
+	"HR": "Croatia",
+	"HT": "Haiti",
+	"HU": "Hungary",
+	"ID": "Indonesia",
+	"IE": "Ireland",
+	"IL": "Israel",
+	"IM": "Isle of Man",
+	"IN": "India",
+	"IO": "British Indian Ocean Territory",
+	"IQ": "Iraq",
+	"IR": "Iran",
+	"IS": "Iceland",
+	"IT": "Italy",
+	"JE": "Jersey",
+	"JM": "Jamaica",
+	"JO": "Jordan",
+	"JP": "Japan",
+	"KE": "Kenya",
+	"KG": "Kyrgyzstan",
+	"KH": "Cambodia",
+	"KI": "Kiribati",
+	"KM": "Comoros",
+	"KN": "Saint Kitts and Nevis",
+	"KP": "North Korea",
+	"KR": "South Korea",
+	"KW": "Kuwait",
+	"KY": "Cayman Islands",
+	"KZ": "Kazakhstan",
+	"LA": "Laos",
+	"LB": "Lebanon",
+	"LC": "Saint Lucia",
+	"LI": "Liechtenstein",
+	"LK": "Sri Lanka",
+	"LR": "Liberia",
+	"LS": "Lesotho",
+	"LT": "Lithuania",
+	"LU": "Luxembourg",
+	"LV": "Latvia",
+	"LY": "Libya",
+	"MA": "Morocco",
+	"MC": "Monaco",
+	"MD": "Moldova",
+	"ME": "Montenegro",
+	"MF": "Saint Martin (French part)",
+	"MG": "Madagascar",
+	"MH": "Marshall Islands",
+	"MK": "North Macedonia",
+	"ML": "Mali",
+	"MM": "Myanmar",
+	"MN": "Mongolia",
+	"MO": "Macao",
+	"MP": "Northern Mariana Islands",
+	"MQ": "Martinique",
+	"MR": "Mauritania",
+	"MS": "Montserrat",
+	"MT": "Malta",
+	"MU": "Mauritius",
+	"MV": "Maldives",
+	"MW": "Malawi",
+	"MX": "Mexico",
+	"MY": "Malaysia",
+	"MZ": "Mozambique",
+	"NA": "Namibia",
+	"NC": "New Caledonia",
+	"NE": "Niger",
+	"NF": "Norfolk Island",
+	"NG": "Nigeria",
+	"NI": "Nicaragua",
+	"NL": "Netherlands",
+	"NO": "Norway",
+	"NP": "Nepal",
+	"NR": "Nauru",
+	"NU": "Niue",
+	"NZ": "New Zealand",
+	"OM": "Oman",
+	"PA": "Panama",
+	"PE": "Peru",
+	"PF": "French Polynesia",
+	"PG": "Papua New Guinea",
+	"PH": "Philippines",
+	"PK": "Pakistan",
+	"PL": "Poland",
+	"PM": "Saint Pierre and Miquelon",
+	"PN": "Pitcairn",
+	"PR": "Puerto Rico",
+	"PS": "Palestine",
+	"PT": "Portugal",
+	"PW": "Palau",
+	"PY": "Paraguay",
+	"QA": "Qatar",
+	"RE": "Réunion",
+	"RO": "Romania",
+	"RS": "Serbia",
+	"RU": "Russia",
+	"RW": "Rwanda",
+	"SA": "Saudi Arabia",
+	"SB": "Solomon Islands",
+	"SC": "Seychelles",
+	"SD": "Sudan",
+	"SE": "Sweden",
+	"SG": "Singapore",
+	"SH": "Saint Helena, Ascension and Tristan da Cunha",
+	"SI": "Slovenia",
+	"SJ": "Svalbard and Jan Mayen",
+	"SK": "Slovakia",
+	"SL": "Sierra Leone",
+	"SM": "San Marino",
+	"SN": "Senegal",
+	"SO": "Somalia",
+	"SR": "Suriname",
+	"SS": "South Sudan",
+	"ST": "Sao Tome and Principe",
+	"SV": "El Salvador",
+	"SX": "Sint Maarten (Dutch part)",
+	"SY": "Syria",
+	"SZ": "Eswatini",
+	"TC": "Turks and Caicos Islands",
+	"TD": "Chad",
+	"TF": "French Southern Territories",
+	"TG": "Togo",
+	"TH": "Thailand",
+	"TJ": "Tajikistan",
+	"TK": "Tokelau",
+	"TL": "Timor-Leste",
+	"TM": "Turkmenistan",
+	"TN": "Tunisia",
+	"TO": "Tonga",
+	"TR": "Türkiye",
+	"TT": "Trinidad and Tobago",
+	"TV": "Tuvalu",
+	"TW": "Taiwan",
+	"TZ": "Tanzania",
+	"UA": "Ukraine",
+	"UG": "Uganda",
+	"UM": "United States Minor Outlying Islands",
+	"US": "United States",
+	"UY": "Uruguay",
+	"UZ": "Uzbekistan",
+	"VA": "Holy See",
+	"VC": "Saint Vincent and the Grenadines",
+	"VE": "Venezuela",
+	"VG": "British Virgin Islands",
+	"VI": "U.S. Virgin Islands",
+	"VN": "Viet Nam",
+	"VU": "Vanuatu",
+	"WF": "Wallis and Futuna",
+	"WS": "Samoa",
+	"YE": "Yemen",
+	"YT": "Mayotte",
+	"ZA": "South Africa",
+	"ZM": "Zambia",
+	"ZW": "Zimbabwe",
+}
+
+// countryRegionCodes are the codes that the firewall accepts in addition
+// to the ISO 3166 country codes.
+var countryRegionCodes = map[string]string{
+	"A1": "Anonymous Proxy",
+	"A2": "Satellite Provider",
+	"AP": "Asia Pacific Region",
+	"EU": "European Union",
+	"O1": "Other Country",
+}
+
+// countryAliases are common names for countries other than their ISO 3166
+// names.
+var countryAliases = map[string]string{
+	"aland islands":                          "AX",
+	"bolivia, plurinational state of":        "BO",
+	"britain":                                "GB",
+	"brunei":                                 "BN",
+	"burma":                                  "MM",
+	"cape verde":                             "CV",
+	"cote d'ivoire":                          "CI",
+	"curacao":                                "CW",
+	"czech republic":                         "CZ",
+	"drc":                                    "CD",
+	"east timor":                             "TL",
+	"great britain":                          "GB",
+	"holland":                                "NL",
+	"iran, islamic republic of":              "IR",
+	"ivory coast":                            "CI",
+	"korea, democratic people's republic of": "KP",
+	"korea, republic of":                     "KR",
+	"lao people's democratic republic":       "LA",
+	"macau":                                  "MO",
+	"macedonia":                              "MK",
+	"moldova, republic of":                   "MD",
+	"palestine, state of":                    "PS",
+	"republic of korea":                      "KR",
+	"reunion":                                "RE",
+	"russian federation":                     "RU",
+	"saint barthelemy":                       "BL",
+	"swaziland":                              "SZ",
+	"syrian arab republic":                   "SY",
+	"taiwan, province of china":              "TW",
+	"tanzania, united republic of":           "TZ",
+	"the netherlands":                        "NL",
+	"turkey":                                 "TR",
+	"uae":                                    "AE",
+	"uk":                                     "GB",
+	"united states of america":               "US",
+	"usa":                                    "US",
+	"vatican":                                "VA",
+	"vatican city":                           "VA",
+	"venezuela, bolivarian republic of":      "VE",
+	"vietnam":                                "VN",
+}
+
+// countryLookup maps the lowercased codes, names and aliases of each country
+// to its code.
+var countryLookup = func() map[string]string {
+	ans := make(map[string]string, 2*len(countryCodes)+len(countryAliases))
+	for _, codes := range []map[string]string{countryCodes, countryRegionCodes} {
+		for code, name := range codes {
+			ans[strings.ToLower(code)] = code
+			ans[countryKey(name)] = code
+		}
+	}
+	for alias, code := range countryAliases {
+		ans[alias] = code
+	}
+
+	return ans
+}()
+
+func countryKey(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// normalizeCountryCode returns the code for the given country code, name or
+// alias, or the value as is if it is unknown.
+func normalizeCountryCode(s string) string {
+	if code, ok := countryLookup[countryKey(s)]; ok {
+		return code
+	}
+
+	return s
+}
+
+// normalizeCountryCodes normalizes each of the given countries.
+func normalizeCountryCodes(list []string) []string {
+	if len(list) == 0 {
+		return list
+	}
+
+	ans := make([]string, 0, len(list))
+	for _, x := range list {
+		ans = append(ans, normalizeCountryCode(x))
+	}
+
+	return ans
+}
+
+// validateCountryCode is the ValidateFunc of a country, which warns about
+// countries given by name or alias, as they are normalized to their code.
+func validateCountryCode(v interface{}, key string) ([]string, []error) {
+	s, _ := v.(string)
+	code, ok := countryLookup[countryKey(s)]
+	if !ok {
+		names := make([]string, 0, len(countryLookup))
+		for name := range countryLookup {
+			names = append(names, name)
+		}
+		msg := fmt.Sprintf("%s: %q is not an ISO 3166 alpha-2 country code", key, s)
+		if match := closestMatch(s, names); match != "" {
+			code = countryLookup[match]
+			msg += fmt.Sprintf(", did you mean %q (%s)?", code, countryName(code))
+		}
+		return nil, []error{errors.New(msg)}
+	}
+
+	if code != s {
+		return []string{fmt.Sprintf("%s: %q is normalized to the country code %q (%s)", key, s, code, countryName(code))}, nil
+	}
+
+	return nil, nil
+}
+
+func countryName(code string) string {
+	if name, ok := countryCodes[code]; ok {
+		return name
+	}
+
+	return countryRegionCodes[code]
+}
+
+// countrySchema is the schema of a set of countries, which normalizes
+// each country to its code.
+func countrySchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeSet,
+		Optional:    true,
+		Description: "List of countries, as ISO 3166 alpha-2 codes.",
+		Set: func(v interface{}) int {
+			return schema.HashString(normalizeCountryCode(v.(string)))
+		},
+		Elem: &schema.Schema{
+			Type:         schema.TypeString,
+			ValidateFunc: validateCountryCode,
+			StateFunc: func(v interface{}) string {
+				return normalizeCountryCode(v.(string))
+			},
+		},
+	}
+}
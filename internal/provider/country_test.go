@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeCountryCode(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{"GB", "GB"},
+		{"gb", "GB"},
+		{"UK", "GB"},
+		{"United  Kingdom", "GB"},
+		{"côte d'ivoire", "CI"},
+		{"Ivory Coast", "CI"},
+		{"EU", "EU"},
+		{"XX", "XX"},
+	} {
+		if got := normalizeCountryCode(tc.in); got != tc.want {
+			t.Errorf("%q: got %q, expected %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestValidateCountryCode(t *testing.T) {
+	if warns, errs := validateCountryCode("DE", "countries"); len(warns) != 0 || len(errs) != 0 {
+		t.Errorf("DE: got warnings %v and errors %v", warns, errs)
+	}
+
+	warns, errs := validateCountryCode("UK", "countries")
+	if len(errs) != 0 {
+		t.Errorf("UK: got errors %v", errs)
+	}
+	if len(warns) != 1 || !strings.Contains(warns[0], `"GB"`) {
+		t.Errorf("UK: got warnings %v", warns)
+	}
+
+	_, errs = validateCountryCode("Germny", "countries")
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `did you mean "DE" (Germany)?`) {
+		t.Errorf("Germny: got errors %v", errs)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/object/url"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -26,7 +25,11 @@ func dataSourceCustomUrlCategory() *schema.Resource {
 }
 
 func readCustomUrlCategoryDataSource(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := url.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := url.NewClient(con)
 
 	style := d.Get(ConfigTypeName).(string)
 	d.Set(ConfigTypeName, style)
@@ -78,6 +81,85 @@ func readCustomUrlCategoryDataSource(ctx context.Context, d *schema.ResourceData
 	return nil
 }
 
+// Data source (list).
+func dataSourceCustomUrlCategories() *schema.Resource {
+	return &schema.Resource{
+		Description: "Data source for retrieving every custom url category in a rulestack.",
+
+		ReadContext: readCustomUrlCategories,
+
+		Schema: objectListSchema(
+			"custom_url_categories", "The custom url categories.",
+			customUrlCategorySchema(false, []string{ConfigTypeName, RulestackName}),
+		),
+	}
+}
+
+func readCustomUrlCategories(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := url.NewClient(con)
+
+	style := d.Get(ConfigTypeName).(string)
+	d.Set(ConfigTypeName, style)
+
+	stack := d.Get(RulestackName).(string)
+
+	tflog.Info(
+		ctx, "read custom url categories",
+		"ds", true,
+		ConfigTypeName, style,
+		RulestackName, stack,
+	)
+
+	list, err := listCustomUrlCategoryNames(ctx, svc, stack, style)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	names := make([]string, 0, len(list))
+	items := make([]interface{}, 0, len(list))
+	for _, name := range list {
+		req := url.ReadInput{
+			Rulestack: stack,
+			Name:      name,
+		}
+		switch style {
+		case CandidateConfig:
+			req.Candidate = true
+		case RunningConfig:
+			req.Running = true
+		}
+
+		res, err := svc.Read(ctx, req)
+		if err != nil {
+			if isObjectNotFound(err) {
+				continue
+			}
+			return diag.FromErr(err)
+		}
+
+		info := res.Response.Candidate
+		if style == RunningConfig {
+			info = res.Response.Running
+		}
+		if info == nil {
+			continue
+		}
+
+		names = append(names, name)
+		items = append(items, dumpCustomUrlCategory(name, *info))
+	}
+
+	d.SetId(configTypeId(style, stack))
+	d.Set("names", names)
+	d.Set("custom_url_categories", items)
+
+	return nil
+}
+
 // Resource.
 func resourceCustomUrlCategory() *schema.Resource {
 	return &schema.Resource{
@@ -88,16 +170,22 @@ func resourceCustomUrlCategory() *schema.Resource {
 		UpdateContext: updateCustomUrlCategory,
 		DeleteContext: deleteCustomUrlCategory,
 
+		CustomizeDiff: customizeDiffAutoCommit(RulestackName),
+
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
 
-		Schema: customUrlCategorySchema(true, []string{ConfigTypeName}),
+		Schema: runningDiffSchema(customUrlCategorySchema(true, []string{ConfigTypeName})),
 	}
 }
 
 func createCustomUrlCategory(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := url.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := url.NewClient(con)
 	o := loadCustomUrlCategory(d)
 	tflog.Info(
 		ctx, "create custom url category",
@@ -115,39 +203,51 @@ func createCustomUrlCategory(ctx context.Context, d *schema.ResourceData, meta i
 }
 
 func readCustomUrlCategory(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := url.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := url.NewClient(con)
 	stack, name, err := parseCustomUrlCategoryId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
 	}
 
-	req := url.ReadInput{
-		Rulestack: stack,
-		Name:      name,
-		Candidate: true,
-	}
 	tflog.Info(
 		ctx, "read custom url category",
-		RulestackName, req.Rulestack,
+		RulestackName, stack,
 		"name", name,
 	)
 
-	res, err := svc.Read(ctx, req)
+	candidate, running, err := readCustomUrlCategoryConfigs(ctx, svc, stack, name)
 	if err != nil {
 		if isObjectNotFound(err) {
 			d.SetId("")
 			return nil
 		}
 		return diag.FromErr(err)
+	} else if candidate == nil {
+		d.SetId("")
+		return nil
 	}
 
-	saveCustomUrlCategory(d, stack, name, *res.Response.Candidate)
+	saveCustomUrlCategory(d, stack, name, *candidate)
+
+	var rdump map[string]interface{}
+	if running != nil {
+		rdump = dumpCustomUrlCategory(name, *running)
+	}
+	saveRunningDiff(d, dumpCustomUrlCategory(name, *candidate), rdump)
 
 	return nil
 }
 
 func updateCustomUrlCategory(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := url.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := url.NewClient(con)
 	o := loadCustomUrlCategory(d)
 	tflog.Info(
 		ctx, "update custom url category",
@@ -163,7 +263,11 @@ func updateCustomUrlCategory(ctx context.Context, d *schema.ResourceData, meta i
 }
 
 func deleteCustomUrlCategory(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := url.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := url.NewClient(con)
 	stack, name, err := parseCustomUrlCategoryId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
@@ -183,6 +287,53 @@ func deleteCustomUrlCategory(ctx context.Context, d *schema.ResourceData, meta i
 	return nil
 }
 
+// listCustomUrlCategoryNames returns the name of every custom url category in
+// the given config.
+func listCustomUrlCategoryNames(ctx context.Context, svc *url.Client, stack, style string) ([]string, error) {
+	return listAll(func(token string) ([]string, string, error) {
+		input := url.ListInput{
+			Rulestack:  stack,
+			NextToken:  token,
+			MaxResults: 100,
+		}
+		switch style {
+		case CandidateConfig:
+			input.Candidate = true
+		case RunningConfig:
+			input.Running = true
+		}
+
+		res, err := svc.List(ctx, input)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if style == RunningConfig {
+			return res.Response.Running, res.Response.NextToken, nil
+		}
+		return res.Response.Candidate, res.Response.NextToken, nil
+	})
+}
+
+// readCustomUrlCategoryConfigs returns the candidate and running config of a
+// custom url category, either of which is nil if it is absent from that
+// config.
+func readCustomUrlCategoryConfigs(ctx context.Context, svc *url.Client, stack, name string) (*url.Info, *url.Info, error) {
+	req := url.ReadInput{
+		Rulestack: stack,
+		Name:      name,
+		Candidate: true,
+		Running:   true,
+	}
+
+	res, err := svc.Read(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res.Response.Candidate, res.Response.Running, nil
+}
+
 // Schema handling.
 func customUrlCategorySchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 	action_values := []string{"none", "alert", "allow", "block", "continue", "override"}
@@ -253,12 +404,20 @@ func loadCustomUrlCategory(d *schema.ResourceData) url.Info {
 
 func saveCustomUrlCategory(d *schema.ResourceData, stack, name string, o url.Info) {
 	d.Set(RulestackName, stack)
-	d.Set("name", name)
-	d.Set("description", o.Description)
-	d.Set("url_list", sliceToSet(o.UrlList))
-	d.Set("action", o.Action)
-	d.Set("audit_comment", o.AuditComment)
-	d.Set("update_token", o.UpdateToken)
+	for key, value := range dumpCustomUrlCategory(name, o) {
+		d.Set(key, value)
+	}
+}
+
+func dumpCustomUrlCategory(name string, o url.Info) map[string]interface{} {
+	return map[string]interface{}{
+		"name":          name,
+		"description":   o.Description,
+		"url_list":      sliceToSet(o.UrlList),
+		"action":        o.Action,
+		"audit_comment": o.AuditComment,
+		"update_token":  o.UpdateToken,
+	}
 }
 
 // Id functions.
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/object/fqdn"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -25,7 +24,11 @@ func dataSourceFqdnList() *schema.Resource {
 }
 
 func readFqdnListDataSource(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := fqdn.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := fqdn.NewClient(con)
 
 	style := d.Get(ConfigTypeName).(string)
 	d.Set(ConfigTypeName, style)
@@ -77,6 +80,85 @@ func readFqdnListDataSource(ctx context.Context, d *schema.ResourceData, meta in
 	return nil
 }
 
+// Data source (list).
+func dataSourceFqdnLists() *schema.Resource {
+	return &schema.Resource{
+		Description: "Data source for retrieving every fqdn list in a rulestack.",
+
+		ReadContext: readFqdnLists,
+
+		Schema: objectListSchema(
+			"fqdn_lists", "The fqdn lists.",
+			fqdnListSchema(false, []string{ConfigTypeName, RulestackName}),
+		),
+	}
+}
+
+func readFqdnLists(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := fqdn.NewClient(con)
+
+	style := d.Get(ConfigTypeName).(string)
+	d.Set(ConfigTypeName, style)
+
+	stack := d.Get(RulestackName).(string)
+
+	tflog.Info(
+		ctx, "read fqdn lists",
+		"ds", true,
+		ConfigTypeName, style,
+		RulestackName, stack,
+	)
+
+	list, err := listFqdnListNames(ctx, svc, stack, style)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	names := make([]string, 0, len(list))
+	items := make([]interface{}, 0, len(list))
+	for _, name := range list {
+		req := fqdn.ReadInput{
+			Rulestack: stack,
+			Name:      name,
+		}
+		switch style {
+		case CandidateConfig:
+			req.Candidate = true
+		case RunningConfig:
+			req.Running = true
+		}
+
+		res, err := svc.Read(ctx, req)
+		if err != nil {
+			if isObjectNotFound(err) {
+				continue
+			}
+			return diag.FromErr(err)
+		}
+
+		info := res.Response.Candidate
+		if style == RunningConfig {
+			info = res.Response.Running
+		}
+		if info == nil {
+			continue
+		}
+
+		names = append(names, name)
+		items = append(items, dumpFqdnList(name, *info))
+	}
+
+	d.SetId(configTypeId(style, stack))
+	d.Set("names", names)
+	d.Set("fqdn_lists", items)
+
+	return nil
+}
+
 // Resource.
 func resourceFqdnList() *schema.Resource {
 	return &schema.Resource{
@@ -87,16 +169,22 @@ func resourceFqdnList() *schema.Resource {
 		UpdateContext: updateFqdnList,
 		DeleteContext: deleteFqdnList,
 
+		CustomizeDiff: customizeDiffAutoCommit(RulestackName),
+
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
 
-		Schema: fqdnListSchema(true, []string{ConfigTypeName}),
+		Schema: runningDiffSchema(fqdnListSchema(true, []string{ConfigTypeName})),
 	}
 }
 
 func createFqdnList(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := fqdn.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := fqdn.NewClient(con)
 	o := loadFqdnList(d)
 	tflog.Info(
 		ctx, "create fqdn list",
@@ -114,39 +202,51 @@ func createFqdnList(ctx context.Context, d *schema.ResourceData, meta interface{
 }
 
 func readFqdnList(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := fqdn.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := fqdn.NewClient(con)
 	stack, name, err := parseFqdnListId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
 	}
 
-	req := fqdn.ReadInput{
-		Rulestack: stack,
-		Name:      name,
-		Candidate: true,
-	}
 	tflog.Info(
 		ctx, "read fqdn list",
-		RulestackName, req.Rulestack,
+		RulestackName, stack,
 		"name", name,
 	)
 
-	res, err := svc.Read(ctx, req)
+	candidate, running, err := readFqdnListConfigs(ctx, svc, stack, name)
 	if err != nil {
 		if isObjectNotFound(err) {
 			d.SetId("")
 			return nil
 		}
 		return diag.FromErr(err)
+	} else if candidate == nil {
+		d.SetId("")
+		return nil
 	}
 
-	saveFqdnList(d, stack, name, *res.Response.Candidate)
+	saveFqdnList(d, stack, name, *candidate)
+
+	var rdump map[string]interface{}
+	if running != nil {
+		rdump = dumpFqdnList(name, *running)
+	}
+	saveRunningDiff(d, dumpFqdnList(name, *candidate), rdump)
 
 	return nil
 }
 
 func updateFqdnList(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := fqdn.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := fqdn.NewClient(con)
 	o := loadFqdnList(d)
 	tflog.Info(
 		ctx, "update fqdn list",
@@ -162,7 +262,11 @@ func updateFqdnList(ctx context.Context, d *schema.ResourceData, meta interface{
 }
 
 func deleteFqdnList(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := fqdn.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := fqdn.NewClient(con)
 	stack, name, err := parseFqdnListId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
@@ -182,6 +286,51 @@ func deleteFqdnList(ctx context.Context, d *schema.ResourceData, meta interface{
 	return nil
 }
 
+// listFqdnListNames returns the name of every fqdn list in the given config.
+func listFqdnListNames(ctx context.Context, svc *fqdn.Client, stack, style string) ([]string, error) {
+	return listAll(func(token string) ([]string, string, error) {
+		input := fqdn.ListInput{
+			Rulestack:  stack,
+			NextToken:  token,
+			MaxResults: 100,
+		}
+		switch style {
+		case CandidateConfig:
+			input.Candidate = true
+		case RunningConfig:
+			input.Running = true
+		}
+
+		res, err := svc.List(ctx, input)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if style == RunningConfig {
+			return res.Response.Running, res.Response.NextToken, nil
+		}
+		return res.Response.Candidate, res.Response.NextToken, nil
+	})
+}
+
+// readFqdnListConfigs returns the candidate and running config of a fqdn
+// list, either of which is nil if it is absent from that config.
+func readFqdnListConfigs(ctx context.Context, svc *fqdn.Client, stack, name string) (*fqdn.Info, *fqdn.Info, error) {
+	req := fqdn.ReadInput{
+		Rulestack: stack,
+		Name:      name,
+		Candidate: true,
+		Running:   true,
+	}
+
+	res, err := svc.Read(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res.Response.Candidate, res.Response.Running, nil
+}
+
 // Schema handling.
 func fqdnListSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 	ans := map[string]*schema.Schema{
@@ -242,11 +391,19 @@ func loadFqdnList(d *schema.ResourceData) fqdn.Info {
 
 func saveFqdnList(d *schema.ResourceData, stack, name string, o fqdn.Info) {
 	d.Set(RulestackName, stack)
-	d.Set("name", name)
-	d.Set("description", o.Description)
-	d.Set("fqdn_list", sliceToSet(o.FqdnList))
-	d.Set("audit_comment", o.AuditComment)
-	d.Set("update_token", o.UpdateToken)
+	for key, value := range dumpFqdnList(name, o) {
+		d.Set(key, value)
+	}
+}
+
+func dumpFqdnList(name string, o fqdn.Info) map[string]interface{} {
+	return map[string]interface{}{
+		"name":          name,
+		"description":   o.Description,
+		"fqdn_list":     sliceToSet(o.FqdnList),
+		"audit_comment": o.AuditComment,
+		"update_token":  o.UpdateToken,
+	}
 }
 
 // Id functions.
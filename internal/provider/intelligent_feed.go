@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/object/feed"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -26,7 +25,11 @@ func dataSourceIntelligentFeed() *schema.Resource {
 }
 
 func readIntelligentFeedDataSource(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := feed.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := feed.NewClient(con)
 
 	style := d.Get(ConfigTypeName).(string)
 	d.Set(ConfigTypeName, style)
@@ -78,6 +81,85 @@ func readIntelligentFeedDataSource(ctx context.Context, d *schema.ResourceData,
 	return nil
 }
 
+// Data source (list).
+func dataSourceIntelligentFeeds() *schema.Resource {
+	return &schema.Resource{
+		Description: "Data source for retrieving every intelligent feed in a rulestack.",
+
+		ReadContext: readIntelligentFeeds,
+
+		Schema: objectListSchema(
+			"intelligent_feeds", "The intelligent feeds.",
+			intelligentFeedSchema(false, []string{ConfigTypeName, RulestackName}),
+		),
+	}
+}
+
+func readIntelligentFeeds(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := feed.NewClient(con)
+
+	style := d.Get(ConfigTypeName).(string)
+	d.Set(ConfigTypeName, style)
+
+	stack := d.Get(RulestackName).(string)
+
+	tflog.Info(
+		ctx, "read intelligent feeds",
+		"ds", true,
+		ConfigTypeName, style,
+		RulestackName, stack,
+	)
+
+	list, err := listIntelligentFeedNames(ctx, svc, stack, style)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	names := make([]string, 0, len(list))
+	items := make([]interface{}, 0, len(list))
+	for _, name := range list {
+		req := feed.ReadInput{
+			Rulestack: stack,
+			Name:      name,
+		}
+		switch style {
+		case CandidateConfig:
+			req.Candidate = true
+		case RunningConfig:
+			req.Running = true
+		}
+
+		res, err := svc.Read(ctx, req)
+		if err != nil {
+			if isObjectNotFound(err) {
+				continue
+			}
+			return diag.FromErr(err)
+		}
+
+		info := res.Response.Candidate
+		if style == RunningConfig {
+			info = res.Response.Running
+		}
+		if info == nil {
+			continue
+		}
+
+		names = append(names, name)
+		items = append(items, dumpIntelligentFeed(name, *info))
+	}
+
+	d.SetId(configTypeId(style, stack))
+	d.Set("names", names)
+	d.Set("intelligent_feeds", items)
+
+	return nil
+}
+
 // Resource.
 func resourceIntelligentFeed() *schema.Resource {
 	return &schema.Resource{
@@ -88,16 +170,22 @@ func resourceIntelligentFeed() *schema.Resource {
 		UpdateContext: updateIntelligentFeed,
 		DeleteContext: deleteIntelligentFeed,
 
+		CustomizeDiff: customizeDiffAutoCommit(RulestackName),
+
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
 
-		Schema: intelligentFeedSchema(true, []string{ConfigTypeName}),
+		Schema: runningDiffSchema(intelligentFeedSchema(true, []string{ConfigTypeName})),
 	}
 }
 
 func createIntelligentFeed(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := feed.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := feed.NewClient(con)
 	o := loadIntelligentFeed(d)
 	tflog.Info(
 		ctx, "create intelligent feed",
@@ -115,39 +203,51 @@ func createIntelligentFeed(ctx context.Context, d *schema.ResourceData, meta int
 }
 
 func readIntelligentFeed(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := feed.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := feed.NewClient(con)
 	stack, name, err := parseIntelligentFeedId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
 	}
 
-	req := feed.ReadInput{
-		Rulestack: stack,
-		Name:      name,
-		Candidate: true,
-	}
 	tflog.Info(
 		ctx, "read intelligent feed",
-		RulestackName, req.Rulestack,
+		RulestackName, stack,
 		"name", name,
 	)
 
-	res, err := svc.Read(ctx, req)
+	candidate, running, err := readIntelligentFeedConfigs(ctx, svc, stack, name)
 	if err != nil {
 		if isObjectNotFound(err) {
 			d.SetId("")
 			return nil
 		}
 		return diag.FromErr(err)
+	} else if candidate == nil {
+		d.SetId("")
+		return nil
 	}
 
-	saveIntelligentFeed(d, stack, name, *res.Response.Candidate)
+	saveIntelligentFeed(d, stack, name, *candidate)
+
+	var rdump map[string]interface{}
+	if running != nil {
+		rdump = dumpIntelligentFeed(name, *running)
+	}
+	saveRunningDiff(d, dumpIntelligentFeed(name, *candidate), rdump)
 
 	return nil
 }
 
 func updateIntelligentFeed(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := feed.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := feed.NewClient(con)
 	o := loadIntelligentFeed(d)
 	tflog.Info(
 		ctx, "update intelligent feed",
@@ -163,7 +263,11 @@ func updateIntelligentFeed(ctx context.Context, d *schema.ResourceData, meta int
 }
 
 func deleteIntelligentFeed(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := feed.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := feed.NewClient(con)
 	stack, name, err := parseIntelligentFeedId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
@@ -183,6 +287,52 @@ func deleteIntelligentFeed(ctx context.Context, d *schema.ResourceData, meta int
 	return nil
 }
 
+// listIntelligentFeedNames returns the name of every intelligent feed in the
+// given config.
+func listIntelligentFeedNames(ctx context.Context, svc *feed.Client, stack, style string) ([]string, error) {
+	return listAll(func(token string) ([]string, string, error) {
+		input := feed.ListInput{
+			Rulestack:  stack,
+			NextToken:  token,
+			MaxResults: 100,
+		}
+		switch style {
+		case CandidateConfig:
+			input.Candidate = true
+		case RunningConfig:
+			input.Running = true
+		}
+
+		res, err := svc.List(ctx, input)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if style == RunningConfig {
+			return res.Response.Running, res.Response.NextToken, nil
+		}
+		return res.Response.Candidate, res.Response.NextToken, nil
+	})
+}
+
+// readIntelligentFeedConfigs returns the candidate and running config of a
+// intelligent feed, either of which is nil if it is absent from that config.
+func readIntelligentFeedConfigs(ctx context.Context, svc *feed.Client, stack, name string) (*feed.Info, *feed.Info, error) {
+	req := feed.ReadInput{
+		Rulestack: stack,
+		Name:      name,
+		Candidate: true,
+		Running:   true,
+	}
+
+	res, err := svc.Read(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res.Response.Candidate, res.Response.Running, nil
+}
+
 // Schema handling.
 func intelligentFeedSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 	type_values := []string{"IP_LIST", "URL_LIST"}
@@ -272,15 +422,23 @@ func loadIntelligentFeed(d *schema.ResourceData) feed.Info {
 
 func saveIntelligentFeed(d *schema.ResourceData, stack, name string, o feed.Info) {
 	d.Set(RulestackName, stack)
-	d.Set("name", name)
-	d.Set("description", o.Description)
-	d.Set("certificate", o.Certificate)
-	d.Set("url", o.Url)
-	d.Set("type", o.Type)
-	d.Set("frequency", o.Frequency)
-	d.Set("time", o.Time)
-	d.Set("audit_comment", o.AuditComment)
-	d.Set("update_token", o.UpdateToken)
+	for key, value := range dumpIntelligentFeed(name, o) {
+		d.Set(key, value)
+	}
+}
+
+func dumpIntelligentFeed(name string, o feed.Info) map[string]interface{} {
+	return map[string]interface{}{
+		"name":          name,
+		"description":   o.Description,
+		"certificate":   o.Certificate,
+		"url":           o.Url,
+		"type":          o.Type,
+		"frequency":     o.Frequency,
+		"time":          o.Time,
+		"audit_comment": o.AuditComment,
+		"update_token":  o.UpdateToken,
+	}
 }
 
 // Id functions.
@@ -0,0 +1,242 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// The header the JWTs are sent in.
+const jwtHeader = "Authorization"
+
+// How long before a JWT expires that it is refreshed.
+var jwtRefreshMargin = 5 * time.Minute
+
+// The kinds of JWTs held by the client.
+const (
+	jwtFirewall = iota
+	jwtRulestack
+)
+
+// jwtRefreshing marks the context of a JWT refresh, whose own API calls must
+// not trigger another refresh.
+type jwtRefreshing struct{}
+
+// jwtRefresher refreshes the client's JWTs, one refresh at a time.
+//
+// The SDK reads the JWTs of the client without any locking, so once the
+// client is in use its JWTs are left alone.  Refreshed JWTs are kept here
+// instead, and the transport sends them in place of the ones in the client.
+type jwtRefresher struct {
+	refresh func(context.Context) (string, string, error)
+
+	mu      sync.Mutex
+	sent    [2]string
+	current [2]string
+}
+
+func newJwtRefresher(con *awsngfw.Client) *jwtRefresher {
+	jwts := [2]string{con.FirewallJwt, con.RulestackJwt}
+
+	return &jwtRefresher{
+		refresh: sdkJwtRefresh(con),
+		sent:    jwts,
+		current: jwts,
+	}
+}
+
+// sdkJwtRefresh returns the func that refreshes the JWTs with the SDK.  The
+// refresh is done on a copy of the client, so that the client itself is not
+// changed while it is in use.
+func sdkJwtRefresh(con *awsngfw.Client) func(context.Context) (string, string, error) {
+	return func(ctx context.Context) (string, string, error) {
+		c := *con
+		if err := c.RefreshJwts(ctx); err != nil {
+			return "", "", err
+		}
+
+		return c.FirewallJwt, c.RulestackJwt, nil
+	}
+}
+
+// start gets the first JWTs and saves them in the client, which must not be
+// in use yet.
+func (r *jwtRefresher) start(ctx context.Context, con *awsngfw.Client) error {
+	fw, rs, err := r.refresh(context.WithValue(ctx, jwtRefreshing{}, true))
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if fw != "" {
+		con.FirewallJwt = fw
+	}
+	if rs != "" {
+		con.RulestackJwt = rs
+	}
+	r.sent = [2]string{con.FirewallJwt, con.RulestackJwt}
+	r.current = r.sent
+
+	return nil
+}
+
+// lookup returns which of the client's JWTs the given one is, if any, along
+// with the current JWT of that kind.
+func (r *jwtRefresher) lookup(jwt string) (int, string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if jwt == "" {
+		return 0, "", false
+	}
+	for kind, v := range r.sent {
+		if v == jwt {
+			return kind, r.current[kind], true
+		}
+	}
+
+	return 0, "", false
+}
+
+// renew refreshes the JWTs, unless the stale JWT was already replaced by a
+// concurrent refresh, returning the current JWT of the same kind.
+func (r *jwtRefresher) renew(ctx context.Context, kind int, stale string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cur := r.current[kind]; cur != stale {
+		return cur, nil
+	}
+
+	tflog.Info(ctx, "refreshing jwts")
+	fw, rs, err := r.refresh(context.WithValue(ctx, jwtRefreshing{}, true))
+	if err != nil {
+		return "", err
+	}
+	if fw != "" {
+		r.current[jwtFirewall] = fw
+	}
+	if rs != "" {
+		r.current[jwtRulestack] = rs
+	}
+
+	return r.current[kind], nil
+}
+
+// jwtRefreshTransport refreshes the JWT of an API call shortly before it
+// expires, and again if the API rejects it as unauthorized.
+type jwtRefreshTransport struct {
+	next http.RoundTripper
+	r    *jwtRefresher
+}
+
+func newJwtRefreshTransport(next http.RoundTripper, r *jwtRefresher) *jwtRefreshTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &jwtRefreshTransport{
+		next: next,
+		r:    r,
+	}
+}
+
+func (t *jwtRefreshTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if ctx.Value(jwtRefreshing{}) != nil {
+		return t.next.RoundTrip(req)
+	}
+
+	// The client sends the JWT it was set up with, which is swapped for the
+	// current one.
+	auth := req.Header.Get(jwtHeader)
+	sent := strings.TrimPrefix(auth, "Bearer ")
+	kind, jwt, ok := t.r.lookup(sent)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	// Save the body so that it can be resent.
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if exp := jwtExpiry(jwt); !exp.IsZero() && time.Until(exp) < jwtRefreshMargin {
+		cur, err := t.r.renew(ctx, kind, jwt)
+		if err != nil {
+			return nil, err
+		}
+		jwt = cur
+	}
+
+	resp, err := t.next.RoundTrip(withJwt(req, strings.Replace(auth, sent, jwt, 1), body))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	cur, err := t.r.renew(ctx, kind, jwt)
+	if err != nil {
+		// Return the original response, the refresh error is logged.
+		tflog.Info(ctx, "jwt refresh failed", "error", err.Error())
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	return t.next.RoundTrip(withJwt(req, strings.Replace(auth, sent, cur, 1), body))
+}
+
+// withJwt returns a copy of the request with the given auth header and body.
+func withJwt(req *http.Request, auth string, body []byte) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set(jwtHeader, auth)
+	if body != nil {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	return r
+}
+
+// jwtExpiry returns when the JWT expires, or the zero time if unknown.
+func jwtExpiry(jwt string) time.Time {
+	tok := strings.Split(jwt, ".")
+	if len(tok) != 3 {
+		return time.Time{}
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(tok[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(data, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(claims.Exp, 0)
+}
@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
+)
+
+func TestJwtRefreshTransportUnauthorized(t *testing.T) {
+	con := &awsngfw.Client{
+		FirewallJwt:  "fw-1",
+		RulestackJwt: "rs-1",
+	}
+	refresher, refreshes := testJwtRefresher(con)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != "payload" {
+			t.Errorf("Got body %q", body)
+		}
+		if auth := r.Header.Get(jwtHeader); auth != "Bearer rs-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{
+		Transport: newJwtRefreshTransport(nil, refresher),
+	}
+
+	// Concurrent calls rejected with the same JWT refresh it once.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+			req.Header.Set(jwtHeader, "Bearer rs-1")
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Errorf("Error: %s", err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Got status %d", resp.StatusCode)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(refreshes); n != 1 {
+		t.Errorf("Got %d refreshes, expected 1", n)
+	}
+
+	// The client keeps the JWTs it was set up with.
+	if con.FirewallJwt != "fw-1" || con.RulestackJwt != "rs-1" {
+		t.Errorf("Client JWTs changed to %q and %q", con.FirewallJwt, con.RulestackJwt)
+	}
+
+	// Later calls send the refreshed JWT without another refresh.
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+	req.Header.Set(jwtHeader, "Bearer "+con.RulestackJwt)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Got status %d", resp.StatusCode)
+	}
+	if n := atomic.LoadInt32(refreshes); n != 1 {
+		t.Errorf("Got %d refreshes, expected 1", n)
+	}
+}
+
+func TestJwtRefreshTransportExpiring(t *testing.T) {
+	con := &awsngfw.Client{
+		FirewallJwt:  testJwt(time.Now().Add(time.Minute)),
+		RulestackJwt: "rs-1",
+	}
+	refresher, refreshes := testJwtRefresher(con)
+
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get(jwtHeader)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{
+		Transport: newJwtRefreshTransport(nil, refresher),
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set(jwtHeader, con.FirewallJwt)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	resp.Body.Close()
+
+	if n := atomic.LoadInt32(refreshes); n != 1 {
+		t.Errorf("Got %d refreshes, expected 1", n)
+	}
+	if auth != "fw-2" {
+		t.Errorf("Sent JWT %q, expected the refreshed one", auth)
+	}
+}
+
+func TestJwtExpiry(t *testing.T) {
+	exp := time.Unix(time.Now().Add(time.Hour).Unix(), 0)
+	if v := jwtExpiry(testJwt(exp)); !v.Equal(exp) {
+		t.Errorf("Got expiry %s, expected %s", v, exp)
+	}
+
+	for _, v := range []string{"", "opaque", "a.b.c", "a." + base64.RawURLEncoding.EncodeToString([]byte(`{}`)) + ".c"} {
+		if exp := jwtExpiry(v); !exp.IsZero() {
+			t.Errorf("%q: got expiry %s", v, exp)
+		}
+	}
+}
+
+// testJwtRefresher returns a refresher that bumps the JWT version suffixes,
+// along with the number of refreshes done.
+func testJwtRefresher(con *awsngfw.Client) (*jwtRefresher, *int32) {
+	var n int32
+	r := newJwtRefresher(con)
+	r.refresh = func(ctx context.Context) (string, string, error) {
+		v := atomic.AddInt32(&n, 1) + 1
+		return fmt.Sprintf("fw-%d", v), fmt.Sprintf("rs-%d", v), nil
+	}
+
+	return r, &n
+}
+
+func testJwt(exp time.Time) string {
+	enc := base64.RawURLEncoding
+	claims := fmt.Sprintf(`{"exp": %d}`, exp.Unix())
+
+	return strings.Join([]string{
+		enc.EncodeToString([]byte(`{"alg": "none"}`)),
+		enc.EncodeToString([]byte(claims)),
+		"sig",
+	}, ".")
+}
@@ -0,0 +1,1056 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
+)
+
+// The account ID the fake API assigns to firewalls created without one.
+const mockAccountId = "123456789012"
+
+// Object types stored under a rulestack, keyed by their path segment, along
+// with the prefix of the candidate / running keys in their responses.
+var mockObjectKinds = map[string]string{
+	"certificates":        "CertificateObject",
+	"feeds":               "IntelligentFeed",
+	"fqdnlists":           "FqdnList",
+	"prefixlists":         "PrefixList",
+	"urlcustomcategories": "URLCategory",
+}
+
+// mockApi is an in-process, stateful fake of the Cloud NGFW REST API.
+//
+// Request bodies are stored verbatim and echoed back in the same envelopes
+// the real API uses, with separate candidate and running copies of every
+// rulestack, rule and object.  Committing copies candidate over running and
+// reverting copies running over candidate.
+// Firewalls stay CREATING / UPDATING / DELETING for a few reads so that
+// status polling gets exercised.
+type mockApi struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu         sync.Mutex
+	rulestacks map[string]*mockRulestack
+	firewalls  map[string]*mockFirewall
+
+	// The applications of each App-ID version.
+	AppIdVersions map[string][]string
+
+	// The details of each application, served for the App-ID versions
+	// that have the application.
+	AppIdApplications map[string]map[string]interface{}
+
+	// Number of reads a firewall stays in a transitional status.
+	FirewallTransitionReads int
+
+	// Every request made, as "METHOD path".
+	Requests []string
+
+	// The status code the next change (POST, PUT or DELETE) fails with
+	// after it is made, like a gateway timing out on a change that the
+	// backend went on to make.
+	FailNextWrite int
+
+	// The status codes that changes to paths ending with the keys fail
+	// with, without being made.
+	FailWrites map[string]int
+}
+
+type mockRulestack struct {
+	State              string
+	Candidate          map[string]interface{}
+	Running            map[string]interface{}
+	Tags               map[string]string
+	Rules              map[string]map[int]*mockEntry
+	Objects            map[string]map[string]*mockEntry
+	CommitStatus       string
+	ValidationStatus   string
+	CommitMessages     []string
+	ValidationMessages []string
+
+	// Number of commits made through the API.
+	Commits int
+}
+
+// mockEntry is a rule or object; a nil config means it is absent from it.
+type mockEntry struct {
+	Candidate map[string]interface{}
+	Running   map[string]interface{}
+}
+
+type mockFirewall struct {
+	AccountId  string
+	Firewall   map[string]interface{}
+	LogProfile map[string]interface{}
+	Tags       map[string]string
+	Status     string
+	Deleted    bool
+	reads      int
+
+	// The rulestack status, Updating after an association change, then
+	// Success, or empty without any rulestacks.
+	RulestackStatus string
+
+	// The App-ID version being upgraded to.
+	pendingAppIdVersion interface{}
+
+	// Whether updates end up UPDATE_FAILED.
+	FailUpdates bool
+
+	// Whether the attachments leave out their availability zone.
+	NoAttachmentZones bool
+}
+
+func newMockApi(t *testing.T) *mockApi {
+	m := &mockApi{
+		t:                       t,
+		rulestacks:              make(map[string]*mockRulestack),
+		firewalls:               make(map[string]*mockFirewall),
+		AppIdVersions:           make(map[string][]string),
+		AppIdApplications:       make(map[string]map[string]interface{}),
+		FirewallTransitionReads: 1,
+	}
+
+	m.server = httptest.NewServer(m)
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+// client returns an SDK client that talks to the fake API.
+func (m *mockApi) client() *awsngfw.Client {
+	u, err := url.Parse(m.server.URL)
+	if err != nil {
+		m.t.Fatalf("Error parsing mock url: %s", err)
+	}
+
+	con := &awsngfw.Client{
+		Host:     u.Host,
+		Protocol: u.Scheme,
+		Region:   "us-east-1",
+		Timeout:  10,
+		Logging:  awsngfw.LogQuiet,
+		Agent:    "terraform-provider-cloudngfwaws/test",
+	}
+
+	if err = con.Setup(); err != nil {
+		m.t.Fatalf("Error setting up mock client: %s", err)
+	}
+
+	// Skip the STS assume role, the fake API does not check JWTs.
+	con.FirewallJwt = "mock-firewall-jwt"
+	con.RulestackJwt = "mock-rulestack-jwt"
+
+	return con
+}
+
+// meta returns what the provider passes to resources as their meta.
+func (m *mockApi) meta() interface{} {
+	return &providerMeta{client: m.client()}
+}
+
+// addRulestack creates an empty, committed rulestack.
+func (m *mockApi) addRulestack(name string) *mockRulestack {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rs := &mockRulestack{
+		State:     "Running",
+		Candidate: map[string]interface{}{"Scope": "Local"},
+		Running:   map[string]interface{}{"Scope": "Local"},
+		Tags:      make(map[string]string),
+		Rules:     make(map[string]map[int]*mockEntry),
+		Objects:   make(map[string]map[string]*mockEntry),
+	}
+	m.rulestacks[name] = rs
+
+	return rs
+}
+
+// addFirewall creates a firewall that is done being created.
+func (m *mockApi) addFirewall(name string) *mockFirewall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fw := &mockFirewall{
+		AccountId: mockAccountId,
+		Firewall: map[string]interface{}{
+			"FirewallName": name,
+			"AccountId":    mockAccountId,
+		},
+		Tags:   make(map[string]string),
+		Status: "CREATE_COMPLETE",
+	}
+	m.firewalls[name] = fw
+
+	return fw
+}
+
+// Rulestack returns the stored rulestack, for use in test assertions.
+func (m *mockApi) Rulestack(name string) *mockRulestack {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.rulestacks[name]
+}
+
+// Firewall returns the stored firewall, for use in test assertions.
+func (m *mockApi) Firewall(name string) *mockFirewall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.firewalls[name]
+}
+
+func (m *mockApi) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var body map[string]interface{}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	if body == nil {
+		body = make(map[string]interface{})
+	}
+
+	m.Requests = append(m.Requests, r.Method+" "+r.URL.Path)
+	if r.Method != http.MethodGet {
+		for suffix, code := range m.FailWrites {
+			if strings.HasSuffix(r.URL.Path, suffix) {
+				m.fail(w, code, http.StatusText(code))
+				return
+			}
+		}
+	}
+	if m.FailNextWrite != 0 && r.Method != http.MethodGet {
+		code := m.FailNextWrite
+		m.FailNextWrite = 0
+		m.route(httptest.NewRecorder(), r, body)
+		m.fail(w, code, http.StatusText(code))
+		return
+	}
+
+	m.route(w, r, body)
+}
+
+func (m *mockApi) route(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	for i, s := range path {
+		switch s {
+		case "tokens":
+			m.respond(w, map[string]interface{}{
+				"TokenId":         "mock-jwt",
+				"SubscriptionKey": "mock-subscription-key",
+			})
+			return
+		case "rulestacks":
+			m.serveRulestacks(w, r, path[i+1:], body)
+			return
+		case "ngfirewalls":
+			m.serveFirewalls(w, r, path[i+1:], body)
+			return
+		case "appidversions":
+			m.serveAppIdVersions(w, path[i+1:])
+			return
+		}
+	}
+
+	m.fail(w, http.StatusNotFound, fmt.Sprintf("unknown path %s", r.URL.Path))
+}
+
+// Rulestacks.
+func (m *mockApi) serveRulestacks(w http.ResponseWriter, r *http.Request, path []string, body map[string]interface{}) {
+	if len(path) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			names := make([]string, 0, len(m.rulestacks))
+			for name := range m.rulestacks {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			m.respond(w, map[string]interface{}{
+				"RuleStacks": names,
+				"NextToken":  "",
+			})
+		case http.MethodPost:
+			name, _ := body["RuleStackName"].(string)
+			if _, ok := m.rulestacks[name]; ok {
+				m.fail(w, http.StatusConflict, fmt.Sprintf("rulestack %q already exists", name))
+				return
+			}
+			m.rulestacks[name] = &mockRulestack{
+				State:     "Uncommitted",
+				Candidate: entryOf(body, "RuleStackEntry"),
+				Tags:      make(map[string]string),
+				Rules:     make(map[string]map[int]*mockEntry),
+				Objects:   make(map[string]map[string]*mockEntry),
+			}
+			m.respond(w, map[string]interface{}{"RuleStackName": name})
+		default:
+			m.fail(w, http.StatusMethodNotAllowed, r.Method)
+		}
+		return
+	}
+
+	name := path[0]
+	rs := m.rulestacks[name]
+	if rs == nil {
+		m.notFound(w, "rulestack", name)
+		return
+	}
+
+	if len(path) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			resp := map[string]interface{}{
+				"RuleStackName":  name,
+				"RuleStackState": rs.State,
+			}
+			if wantCandidate(r) {
+				resp["RuleStackCandidate"] = rs.Candidate
+			}
+			if wantRunning(r) {
+				resp["RuleStackRunning"] = rs.Running
+			}
+			m.respond(w, resp)
+		case http.MethodPut:
+			rs.Candidate = entryOf(body, "RuleStackEntry")
+			rs.State = "Uncommitted"
+			m.respond(w, map[string]interface{}{"RuleStackName": name})
+		case http.MethodDelete:
+			delete(m.rulestacks, name)
+			m.respond(w, map[string]interface{}{"RuleStackName": name})
+		default:
+			m.fail(w, http.StatusMethodNotAllowed, r.Method)
+		}
+		return
+	}
+
+	switch path[1] {
+	case "commit":
+		if r.Method == http.MethodPost {
+			rs.Commits++
+			m.commit(rs)
+		}
+		m.respond(w, map[string]interface{}{
+			"RuleStackName":      name,
+			"CommitStatus":       rs.CommitStatus,
+			"ValidationStatus":   rs.ValidationStatus,
+			"CommitMessages":     rs.CommitMessages,
+			"ValidationMessages": rs.ValidationMessages,
+		})
+	case "revert":
+		m.revert(rs)
+		m.respond(w, map[string]interface{}{"RuleStackName": name})
+	case "validate":
+		rs.ValidationStatus = "Success"
+		if len(rs.ValidationMessages) > 0 {
+			rs.ValidationStatus = "Failed"
+		}
+		m.respond(w, map[string]interface{}{"RuleStackName": name})
+	case "tags":
+		m.serveTags(w, r, rs.Tags, body)
+	case "rulelists":
+		m.serveRules(w, r, name, rs, path[2:], body)
+	default:
+		prefix, ok := mockObjectKinds[path[1]]
+		if !ok {
+			m.fail(w, http.StatusNotFound, fmt.Sprintf("unknown rulestack path %q", path[1]))
+			return
+		}
+		m.serveObjects(w, r, rs, path[1], prefix, path[2:], body)
+	}
+}
+
+// commit copies the candidate config to the running config, unless a
+// validation failure was set up by the test beforehand.
+func (m *mockApi) commit(rs *mockRulestack) {
+	if len(rs.ValidationMessages) > 0 {
+		rs.ValidationStatus = "Failed"
+		rs.CommitStatus = "Failed"
+		rs.CommitMessages = []string{"commit failed due to validation errors"}
+		return
+	}
+
+	rs.Running = copyEntry(rs.Candidate)
+	for _, rules := range rs.Rules {
+		for priority, e := range rules {
+			if e.Candidate == nil {
+				delete(rules, priority)
+				continue
+			}
+			e.Running = copyEntry(e.Candidate)
+		}
+	}
+	for _, objs := range rs.Objects {
+		for name, e := range objs {
+			if e.Candidate == nil {
+				delete(objs, name)
+				continue
+			}
+			e.Running = copyEntry(e.Candidate)
+		}
+	}
+
+	rs.State = "Running"
+	rs.CommitStatus = "Success"
+	rs.ValidationStatus = "Success"
+	rs.CommitMessages = nil
+}
+
+// revert copies the running config back over the candidate config.
+func (m *mockApi) revert(rs *mockRulestack) {
+	rs.Candidate = copyEntry(rs.Running)
+	for _, rules := range rs.Rules {
+		for priority, e := range rules {
+			if e.Running == nil {
+				delete(rules, priority)
+				continue
+			}
+			e.Candidate = copyEntry(e.Running)
+		}
+	}
+	for _, objs := range rs.Objects {
+		for name, e := range objs {
+			if e.Running == nil {
+				delete(objs, name)
+				continue
+			}
+			e.Candidate = copyEntry(e.Running)
+		}
+	}
+
+	rs.State = "Running"
+}
+
+// dirty marks the rulestack as having uncommitted changes.
+func (rs *mockRulestack) dirty() {
+	rs.State = "Uncommitted"
+}
+
+// Security rules.
+func (m *mockApi) serveRules(w http.ResponseWriter, r *http.Request, stack string, rs *mockRulestack, path []string, body map[string]interface{}) {
+	if len(path) == 0 {
+		m.fail(w, http.StatusNotFound, "missing rule list")
+		return
+	}
+
+	rlist := path[0]
+	rules := rs.Rules[rlist]
+	if rules == nil {
+		rules = make(map[int]*mockEntry)
+		rs.Rules[rlist] = rules
+	}
+
+	if len(path) == 1 {
+		priorities := make([]int, 0, len(rules))
+		for priority := range rules {
+			priorities = append(priorities, priority)
+		}
+		sort.Ints(priorities)
+
+		var candidate, running []interface{}
+		for _, priority := range priorities {
+			e := rules[priority]
+			if e.Candidate != nil {
+				candidate = append(candidate, map[string]interface{}{
+					"Priority": priority,
+					"RuleName": e.Candidate["RuleName"],
+				})
+			}
+			if e.Running != nil {
+				running = append(running, map[string]interface{}{
+					"Priority": priority,
+					"RuleName": e.Running["RuleName"],
+				})
+			}
+		}
+
+		m.respond(w, map[string]interface{}{
+			"RuleStackName":      stack,
+			"RuleListName":       rlist,
+			"RuleEntryCandidate": candidate,
+			"RuleEntryRunning":   running,
+			"NextToken":          "",
+		})
+		return
+	}
+
+	if len(path) != 3 || path[1] != "priorities" {
+		m.fail(w, http.StatusNotFound, "unknown rule path")
+		return
+	}
+
+	priority, err := strconv.Atoi(path[2])
+	if err != nil {
+		m.fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	e := rules[priority]
+	exists := e != nil && e.Candidate != nil
+
+	switch r.Method {
+	case http.MethodPost:
+		if exists {
+			m.fail(w, http.StatusConflict, fmt.Sprintf("priority %d is already in use", priority))
+			return
+		}
+		if msg := mockRuleNameInUse(rules, priority, body); msg != "" {
+			m.fail(w, http.StatusConflict, msg)
+			return
+		}
+		if e == nil {
+			e = &mockEntry{}
+			rules[priority] = e
+		}
+		e.Candidate = entryOf(body, "RuleEntry")
+		rs.dirty()
+	case http.MethodPut:
+		if !exists {
+			m.notFound(w, "rule", path[2])
+			return
+		}
+		if msg := mockRuleNameInUse(rules, priority, body); msg != "" {
+			m.fail(w, http.StatusConflict, msg)
+			return
+		}
+		e.Candidate = entryOf(body, "RuleEntry")
+		rs.dirty()
+	case http.MethodDelete:
+		if !exists {
+			m.notFound(w, "rule", path[2])
+			return
+		}
+		e.Candidate = nil
+		rs.dirty()
+	case http.MethodGet:
+		candidate := exists && wantCandidate(r)
+		running := e != nil && e.Running != nil && wantRunning(r)
+		if !candidate && !running {
+			m.notFound(w, "rule", path[2])
+			return
+		}
+	}
+
+	resp := map[string]interface{}{
+		"RuleStackName": stack,
+		"RuleListName":  rlist,
+		"Priority":      priority,
+	}
+	if e != nil {
+		if e.Candidate != nil && wantCandidate(r) {
+			resp["RuleEntryCandidate"] = e.Candidate
+		}
+		if e.Running != nil && wantRunning(r) {
+			resp["RuleEntryRunning"] = e.Running
+		}
+	}
+	m.respond(w, resp)
+}
+
+// mockRuleNameInUse returns why the rule in the body cannot be written to
+// the priority if another priority has a rule of the same name.
+func mockRuleNameInUse(rules map[int]*mockEntry, priority int, body map[string]interface{}) string {
+	name := entryOf(body, "RuleEntry")["RuleName"]
+	for p, e := range rules {
+		if p != priority && e.Candidate != nil && e.Candidate["RuleName"] == name {
+			return fmt.Sprintf("rule %v already exists at priority %d", name, p)
+		}
+	}
+
+	return ""
+}
+
+// Rulestack objects (prefix lists, fqdn lists, certificates, etc).
+func (m *mockApi) serveObjects(w http.ResponseWriter, r *http.Request, rs *mockRulestack, kind, prefix string, path []string, body map[string]interface{}) {
+	objs := rs.Objects[kind]
+	if objs == nil {
+		objs = make(map[string]*mockEntry)
+		rs.Objects[kind] = objs
+	}
+
+	if len(path) == 0 {
+		names := make([]string, 0, len(objs))
+		for name := range objs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var candidate, running []string
+		for _, name := range names {
+			if objs[name].Candidate != nil {
+				candidate = append(candidate, name)
+			}
+			if objs[name].Running != nil {
+				running = append(running, name)
+			}
+		}
+
+		m.respond(w, map[string]interface{}{
+			prefix + "Candidate": candidate,
+			prefix + "Running":   running,
+			"NextToken":          "",
+		})
+		return
+	}
+
+	name := path[0]
+	e := objs[name]
+	exists := e != nil && e.Candidate != nil
+
+	switch r.Method {
+	case http.MethodPost:
+		if exists {
+			m.fail(w, http.StatusConflict, fmt.Sprintf("%s %q already exists", kind, name))
+			return
+		}
+		if e == nil {
+			e = &mockEntry{}
+			objs[name] = e
+		}
+		e.Candidate = body
+		rs.dirty()
+	case http.MethodPut:
+		if !exists {
+			m.notFound(w, kind, name)
+			return
+		}
+		e.Candidate = body
+		rs.dirty()
+	case http.MethodDelete:
+		if !exists {
+			m.notFound(w, kind, name)
+			return
+		}
+		e.Candidate = nil
+		rs.dirty()
+	case http.MethodGet:
+		candidate := exists && wantCandidate(r)
+		running := e != nil && e.Running != nil && wantRunning(r)
+		if !candidate && !running {
+			m.notFound(w, kind, name)
+			return
+		}
+	}
+
+	resp := map[string]interface{}{
+		"Name": name,
+	}
+	if e != nil {
+		if e.Candidate != nil && wantCandidate(r) {
+			resp[prefix+"Candidate"] = e.Candidate
+		}
+		if e.Running != nil && wantRunning(r) {
+			resp[prefix+"Running"] = e.Running
+		}
+	}
+	m.respond(w, resp)
+}
+
+// Firewalls.
+func (m *mockApi) serveFirewalls(w http.ResponseWriter, r *http.Request, path []string, body map[string]interface{}) {
+	if len(path) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			names := make([]string, 0, len(m.firewalls))
+			for name, fw := range m.firewalls {
+				if !fw.Deleted {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+
+			list := make([]interface{}, 0, len(names))
+			for _, name := range names {
+				list = append(list, map[string]interface{}{
+					"FirewallName": name,
+					"AccountId":    m.firewalls[name].AccountId,
+				})
+			}
+			m.respond(w, map[string]interface{}{
+				"Firewalls": list,
+				"NextToken": "",
+			})
+		case http.MethodPost:
+			name, _ := body["FirewallName"].(string)
+			if fw, ok := m.firewalls[name]; ok && !fw.Deleted {
+				m.fail(w, http.StatusConflict, fmt.Sprintf("firewall %q already exists", name))
+				return
+			}
+			aid, _ := body["AccountId"].(string)
+			if aid == "" {
+				aid = mockAccountId
+				body["AccountId"] = aid
+			}
+			fw := &mockFirewall{
+				AccountId: aid,
+				Firewall:  body,
+				Tags:      make(map[string]string),
+				Status:    "CREATING",
+			}
+			applyTags(fw.Tags, body)
+			delete(body, "Tags")
+			m.firewalls[name] = fw
+			m.respond(w, map[string]interface{}{
+				"FirewallName": name,
+				"AccountId":    aid,
+			})
+		default:
+			m.fail(w, http.StatusMethodNotAllowed, r.Method)
+		}
+		return
+	}
+
+	name := path[0]
+	fw := m.firewalls[name]
+	if fw == nil || (fw.Deleted && fw.reads >= m.FirewallTransitionReads) {
+		m.notFound(w, "firewall", name)
+		return
+	}
+
+	if len(path) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			// Tags are served from the tagging API's copy.
+			info := make(map[string]interface{}, len(fw.Firewall)+1)
+			for key, value := range fw.Firewall {
+				info[key] = value
+			}
+			info["Tags"] = tagList(fw.Tags)
+			m.respond(w, map[string]interface{}{
+				"Firewall": info,
+				"Status":   m.firewallStatus(fw),
+			})
+		case http.MethodDelete:
+			fw.Deleted = true
+			fw.Status = "DELETING"
+			fw.reads = 0
+			m.respond(w, map[string]interface{}{"FirewallName": name})
+		default:
+			m.fail(w, http.StatusMethodNotAllowed, r.Method)
+		}
+		return
+	}
+
+	switch path[1] {
+	case "logprofile":
+		if r.Method != http.MethodGet {
+			fw.LogProfile = body
+		}
+		if fw.LogProfile == nil {
+			m.notFound(w, "log profile", name)
+			return
+		}
+		m.respond(w, fw.LogProfile)
+	case "tags":
+		m.serveTags(w, r, fw.Tags, body)
+	case "subnets":
+		mappings, _ := fw.Firewall["SubnetMappings"].([]interface{})
+		remove := make(map[string]bool)
+		if list, ok := body["DisassociateSubnetMappings"].([]interface{}); ok {
+			for _, x := range list {
+				remove[subnetKey(x)] = true
+			}
+		}
+		kept := make([]interface{}, 0, len(mappings))
+		for _, x := range mappings {
+			if !remove[subnetKey(x)] {
+				kept = append(kept, x)
+			}
+		}
+		if list, ok := body["AssociateSubnetMappings"].([]interface{}); ok {
+			kept = append(kept, list...)
+		}
+		fw.Firewall["SubnetMappings"] = kept
+		m.update(fw)
+		m.respond(w, map[string]interface{}{"FirewallName": name})
+	default:
+		// Description, content version, rulestack association and the like.
+		// Content upgrades show up once the update is done.
+		if v, ok := body["AppIdVersion"]; ok {
+			fw.pendingAppIdVersion = v
+			delete(body, "AppIdVersion")
+		}
+		for key, value := range body {
+			fw.Firewall[key] = value
+		}
+		m.update(fw)
+		if path[1] == "rulestack" {
+			// Rulestacks left out of the association are disassociated.
+			for _, key := range []string{"RuleStackName", "GlobalRuleStackName"} {
+				if _, ok := body[key]; !ok {
+					fw.Firewall[key] = ""
+				}
+			}
+			fw.RulestackStatus = "Updating"
+		}
+		m.respond(w, map[string]interface{}{"FirewallName": name})
+	}
+}
+
+// associatedStatus is the settled rulestack status of the firewall, which
+// the API leaves empty when no rulestack is associated.
+func associatedStatus(fw *mockFirewall) string {
+	rs, _ := fw.Firewall["RuleStackName"].(string)
+	grs, _ := fw.Firewall["GlobalRuleStackName"].(string)
+	if rs == "" && grs == "" {
+		return ""
+	}
+
+	return "Success"
+}
+
+func (m *mockApi) update(fw *mockFirewall) {
+	fw.Status = "UPDATING"
+	fw.reads = 0
+}
+
+// firewallStatus advances the firewall out of any transitional status once
+// it has been read enough times.
+func (m *mockApi) firewallStatus(fw *mockFirewall) map[string]interface{} {
+	fw.reads++
+	if fw.reads > m.FirewallTransitionReads {
+		switch fw.Status {
+		case "CREATING":
+			fw.Status = "CREATE_COMPLETE"
+		case "UPDATING":
+			fw.Status = "UPDATE_COMPLETE"
+			if fw.FailUpdates {
+				fw.Status = "UPDATE_FAILED"
+				fw.pendingAppIdVersion = nil
+			}
+		}
+		fw.RulestackStatus = associatedStatus(fw)
+		if fw.pendingAppIdVersion != nil {
+			fw.Firewall["AppIdVersion"] = fw.pendingAppIdVersion
+			fw.pendingAppIdVersion = nil
+		}
+	}
+	if fw.RulestackStatus == "" {
+		fw.RulestackStatus = associatedStatus(fw)
+	}
+
+	status := "ACCEPTED"
+	if fw.Status == "CREATING" {
+		status = "CREATING"
+	}
+
+	mappings, _ := fw.Firewall["SubnetMappings"].([]interface{})
+	attachments := make([]interface{}, 0, len(mappings))
+	for i, x := range mappings {
+		sm, _ := x.(map[string]interface{})
+		subnet, _ := sm["SubnetId"].(string)
+		az, _ := sm["AvailabilityZone"].(string)
+		if fw.NoAttachmentZones {
+			az = ""
+		} else if az == "" {
+			az = fmt.Sprintf("us-east-1%c", 'a'+i)
+		}
+		attachments = append(attachments, map[string]interface{}{
+			"EndpointId":       fmt.Sprintf("vpce-%08d", i),
+			"EniId":            fmt.Sprintf("eni-%08d", i),
+			"SubnetId":         subnet,
+			"AvailabilityZone": az,
+			"Status":           status,
+		})
+	}
+
+	return map[string]interface{}{
+		"FirewallStatus":  fw.Status,
+		"RuleStackStatus": fw.RulestackStatus,
+		"Attachments":     attachments,
+	}
+}
+
+func subnetKey(v interface{}) string {
+	m, _ := v.(map[string]interface{})
+	if s, _ := m["SubnetId"].(string); s != "" {
+		return s
+	}
+	if s, _ := m["AvailabilityZoneId"].(string); s != "" {
+		return s
+	}
+	s, _ := m["AvailabilityZone"].(string)
+	return s
+}
+
+// App-ID versions.
+func (m *mockApi) serveAppIdVersions(w http.ResponseWriter, path []string) {
+	if len(path) == 0 {
+		versions := make([]string, 0, len(m.AppIdVersions))
+		for v := range m.AppIdVersions {
+			versions = append(versions, v)
+		}
+		sort.Strings(versions)
+		m.respond(w, map[string]interface{}{
+			"AppIdVersions": versions,
+			"NextToken":     "",
+		})
+		return
+	}
+
+	apps, ok := m.AppIdVersions[path[0]]
+	if !ok {
+		m.notFound(w, "app-id version", path[0])
+		return
+	}
+	if len(path) > 2 && path[1] == "appname" {
+		details, ok := m.AppIdApplications[path[2]]
+		found := false
+		for _, app := range apps {
+			found = found || app == path[2]
+		}
+		if !ok || !found {
+			m.notFound(w, "application", path[2])
+			return
+		}
+		ans := map[string]interface{}{
+			"AppIdVersion": path[0],
+			"Name":         path[2],
+		}
+		for k, v := range details {
+			ans[k] = v
+		}
+		m.respond(w, ans)
+		return
+	}
+
+	m.respond(w, map[string]interface{}{
+		"AppIdVersion": path[0],
+		"Applications": apps,
+		"NextToken":    "",
+	})
+}
+
+// Tags.
+func (m *mockApi) serveTags(w http.ResponseWriter, r *http.Request, tags map[string]string, body map[string]interface{}) {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		applyTags(tags, body)
+	case http.MethodDelete:
+		keys := r.URL.Query()["tagkeys"]
+		if list, ok := body["TagKeys"].([]interface{}); ok {
+			for _, x := range list {
+				if s, ok := x.(string); ok {
+					keys = append(keys, s)
+				}
+			}
+		}
+		for _, key := range keys {
+			delete(tags, key)
+		}
+	}
+
+	m.respond(w, map[string]interface{}{
+		"Tags":      tagList(tags),
+		"NextToken": "",
+	})
+}
+
+// applyTags adds the tags in the request body.
+func applyTags(tags map[string]string, body map[string]interface{}) {
+	list, _ := body["Tags"].([]interface{})
+	for _, x := range list {
+		t, _ := x.(map[string]interface{})
+		key, _ := t["Key"].(string)
+		value, _ := t["Value"].(string)
+		tags[key] = value
+	}
+}
+
+// tagList returns the tags in the order and format the API uses.
+func tagList(tags map[string]string) []interface{} {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	list := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, map[string]interface{}{
+			"Key":   key,
+			"Value": tags[key],
+		})
+	}
+
+	return list
+}
+
+// Responses.
+func (m *mockApi) respond(w http.ResponseWriter, resp interface{}) {
+	m.write(w, http.StatusOK, map[string]interface{}{
+		"Response": resp,
+		"ResponseStatus": map[string]interface{}{
+			"ErrorCode": 0,
+		},
+	})
+}
+
+func (m *mockApi) notFound(w http.ResponseWriter, kind, name string) {
+	m.fail(w, http.StatusNotFound, fmt.Sprintf("%s %q does not exist", kind, name))
+}
+
+func (m *mockApi) fail(w http.ResponseWriter, code int, reason string) {
+	m.write(w, code, map[string]interface{}{
+		"ResponseStatus": map[string]interface{}{
+			"ErrorCode": code,
+			"Reason":    reason,
+		},
+	})
+}
+
+func (m *mockApi) write(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		m.t.Errorf("Error encoding mock response: %s", err)
+	}
+}
+
+// Helpers.
+func wantCandidate(r *http.Request) bool {
+	return r.URL.Query().Get("candidate") == "true" || !wantRunningOnly(r)
+}
+
+func wantRunning(r *http.Request) bool {
+	return r.URL.Query().Get("running") == "true"
+}
+
+func wantRunningOnly(r *http.Request) bool {
+	return wantRunning(r) && r.URL.Query().Get("candidate") != "true"
+}
+
+// entryOf returns body[key] if the body wraps the entry, otherwise the body.
+func entryOf(body map[string]interface{}, key string) map[string]interface{} {
+	if v, ok := body[key].(map[string]interface{}); ok {
+		return v
+	}
+
+	return body
+}
+
+func copyEntry(v map[string]interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+
+	b, _ := json.Marshal(v)
+	var ans map[string]interface{}
+	_ = json.Unmarshal(b, &ans)
+
+	return ans
+}
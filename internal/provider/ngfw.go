@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	ngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/tag/firewall"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
@@ -34,6 +36,8 @@ func dataSourceNgfws() *schema.Resource {
 				Computed:    true,
 				Description: "Token for the next page of results.",
 			},
+			"all_pages":         allPagesSchema(),
+			"max_total_results": maxTotalResultsSchema(),
 			"vpc_ids": {
 				Type:        schema.TypeList,
 				Optional:    true,
@@ -72,12 +76,16 @@ func dataSourceNgfw() *schema.Resource {
 
 		ReadContext: readNgfwDataSource,
 
-		Schema: ngfwSchema(false, nil),
+		Schema: ngfwSchema(false, []string{"associate_subnet_mappings", "disassociate_subnet_mappings", "previous_app_id_version"}),
 	}
 }
 
 func readNgfwDataSource(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := ngfw.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := ngfw.NewClient(con)
 
 	name := d.Get("name").(string)
 	account_id := d.Get("account_id").(string)
@@ -112,7 +120,11 @@ func readNgfwDataSource(ctx context.Context, d *schema.ResourceData, meta interf
 }
 
 func readNgfws(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := ngfw.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := ngfw.NewClient(con)
 
 	vpc_ids := make([]string, len(d.Get("vpc_ids").([]interface{})), len(d.Get("vpc_ids").([]interface{})))
 	for i, id := range d.Get("vpc_ids").([]interface{}) {
@@ -134,7 +146,23 @@ func readNgfws(ctx context.Context, d *schema.ResourceData, meta interface{}) di
 		"vpc_ids", vpc_ids,
 	)
 
-	ans, err := svc.List(ctx, input)
+	var instances []interface{}
+	next, err := readPages(input.NextToken, d.Get("all_pages").(bool), d.Get("max_total_results").(int), func(token string) (int, string, error) {
+		input.NextToken = token
+		ans, err := svc.List(ctx, input)
+		if err != nil {
+			return 0, "", err
+		}
+
+		for _, instance := range ans.Response.Firewalls {
+			instances = append(instances, map[string]interface{}{
+				"name":       instance.Name,
+				"account_id": instance.AccountId,
+			})
+		}
+
+		return len(ans.Response.Firewalls), ans.Response.NextToken, nil
+	})
 	if err != nil {
 		if isObjectNotFound(err) {
 			d.SetId("")
@@ -144,20 +172,11 @@ func readNgfws(ctx context.Context, d *schema.ResourceData, meta interface{}) di
 	}
 
 	d.SetId(strings.Join(
-		append([]string{strconv.Itoa(input.MaxResults), input.NextToken}, input.VpcIds...),
+		append([]string{strconv.Itoa(input.MaxResults), d.Get("next_token").(string)}, input.VpcIds...),
 		IdSeparator,
 	))
 
-	d.Set("next_token", ans.Response.NextToken)
-
-	instances := make([]interface{}, len(ans.Response.Firewalls), len(ans.Response.Firewalls))
-	for i, instance := range ans.Response.Firewalls {
-
-		instances[i] = map[string]interface{}{
-			"name":       instance.Name,
-			"account_id": instance.AccountId,
-		}
-	}
+	d.Set("next_token", next)
 
 	d.Set("instances", instances)
 
@@ -178,12 +197,24 @@ func resourceNgfw() *schema.Resource {
 			State: schema.ImportStatePassthrough,
 		},
 
-		Schema: ngfwSchema(true, []string{"status", "endpoint_service_name"}),
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+			Update: schema.DefaultTimeout(60 * time.Minute),
+			Delete: schema.DefaultTimeout(60 * time.Minute),
+		},
+
+		CustomizeDiff: customizeDiffNgfw,
+
+		Schema: ngfwSchema(true, []string{"status"}),
 	}
 }
 
 func createNgfw(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := ngfw.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := ngfw.NewClient(con)
 	name := d.Get("name").(string)
 	o := loadNgfw(ctx, d)
 
@@ -203,11 +234,19 @@ func createNgfw(ctx context.Context, d *schema.ResourceData, meta interface{}) d
 	id := buildNgfwId(account_id, name)
 	d.SetId(id)
 
+	if diags := waitForNgfw(ctx, svc, account_id, name, d.Timeout(schema.TimeoutCreate)); diags.HasError() {
+		return diags
+	}
+
 	return readNgfw(ctx, d, meta)
 }
 
 func readNgfw(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := ngfw.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := ngfw.NewClient(con)
 
 	account_id, name, err := parseNgfwId(d.Id())
 	if err != nil {
@@ -233,13 +272,151 @@ func readNgfw(ctx context.Context, d *schema.ResourceData, meta interface{}) dia
 		return diag.FromErr(err)
 	}
 
+	// Remember the version that an automatic upgrade replaced.
+	if prev := d.Get("app_id_version").(string); !d.IsNewResource() && prev != "" && prev != res.Response.Firewall.AppIdVersion {
+		d.Set("previous_app_id_version", prev)
+	}
+
 	saveNgfw(ctx, d, name, *res.Response)
 
+	// Only a firewall with tags in its config manages them.  The config is
+	// only known when the firewall is changed, not when it is refreshed.
+	if raw := d.GetRawConfig(); !raw.IsNull() && !raw.GetAttr(TagsName).IsNull() {
+		return claimNgfwTags(meta, con, account_id, name, ngfwTagsInline)
+	}
+
+	return nil
+}
+
+func customizeDiffNgfw(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.NewValueKnown("endpoint_mode") {
+		mode := d.Get("endpoint_mode").(string)
+		for i := range d.Get("subnet_mapping").([]interface{}) {
+			if err := checkSubnetMapping(d, mode, i); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := customizeDiffNgfwAppIdVersion(ctx, d, meta); err != nil {
+		return err
+	}
+
+	// A new firewall gets all of its subnet mappings.
+	if d.Id() == "" || d.HasChange("endpoint_mode") {
+		return nil
+	}
+
+	// Show which subnet mappings the update associates and disassociates.
+	var assoc, disassoc []interface{}
+	if d.HasChange("subnet_mapping") {
+		if !d.NewValueKnown("subnet_mapping") {
+			for _, key := range []string{"associate_subnet_mappings", "disassociate_subnet_mappings"} {
+				if err := d.SetNewComputed(key); err != nil {
+					return err
+				}
+			}
+		} else {
+			prev, cur := d.GetChange("subnet_mapping")
+			a, b := diffSubnetMappings(
+				d.Get("endpoint_mode").(string),
+				loadSubnetMappings(ctx, cur.([]interface{})),
+				loadSubnetMappings(ctx, prev.([]interface{})),
+			)
+			assoc, disassoc = subnetMappingNames(a), subnetMappingNames(b)
+		}
+	}
+
+	if len(assoc) != 0 || len(d.Get("associate_subnet_mappings").([]interface{})) != 0 {
+		if err := d.SetNew("associate_subnet_mappings", assoc); err != nil {
+			return err
+		}
+	}
+	if len(disassoc) != 0 || len(d.Get("disassociate_subnet_mappings").([]interface{})) != 0 {
+		if err := d.SetNew("disassociate_subnet_mappings", disassoc); err != nil {
+			return err
+		}
+	}
+
+	// Endpoints follow the subnet mappings.
+	if d.HasChange("subnet_mapping") {
+		for _, key := range []string{"endpoint_ids", "eni_ids", "subnet_ids"} {
+			if err := d.SetNewComputed(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// customizeDiffNgfwAppIdVersion checks that a new App-ID version exists, is
+// not below the rulestack's minimum and is not fighting an App-ID rollout, and
+// plans the previous version output.
+func customizeDiffNgfwAppIdVersion(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.HasChange("app_id_version") || !d.NewValueKnown("app_id_version") {
+		return nil
+	}
+
+	if prev, _ := d.GetChange("app_id_version"); d.Id() != "" && prev.(string) != "" {
+		if err := d.SetNew("previous_app_id_version", prev); err != nil {
+			return err
+		}
+	}
+
+	version := d.Get("app_id_version").(string)
+	if version == "" {
+		return nil
+	}
+
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return err
+	}
+
+	if d.NewValueKnown("name") && d.NewValueKnown("account_id") {
+		if err = claimNgfwAppIdVersion(meta, con, d.Get("account_id").(string), d.Get("name").(string), appIdOwnerNgfw, version); err != nil {
+			return err
+		}
+	}
+
+	versions, err := cachedAppIdVersions(ctx, meta, con)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, v := range versions {
+		if v == version {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("app_id_version %q is not an available App-ID version", version)
+	}
+
+	if !d.NewValueKnown(RulestackName) {
+		return nil
+	}
+	name := d.Get(RulestackName).(string)
+
+	minimum, err := rulestackMinAppIdVersion(ctx, con, name)
+	if err != nil {
+		return err
+	}
+	if minimum != "" && compareAppIdVersions(version, minimum) < 0 {
+		return fmt.Errorf("app_id_version %q is below the minimum_app_id_version %q of rulestack %q", version, minimum, name)
+	}
+
 	return nil
 }
 
 func updateNgfw(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := ngfw.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := ngfw.NewClient(con)
 	o := loadNgfw(ctx, d)
 
 	tflog.Info(
@@ -257,6 +434,8 @@ func updateNgfw(ctx context.Context, d *schema.ResourceData, meta interface{}) d
 		return diag.FromErr(err)
 	}
 
+	var changed bool
+
 	if d.HasChange("description") {
 		input := ngfw.Info{
 			Name:        o.Name,
@@ -266,6 +445,7 @@ func updateNgfw(ctx context.Context, d *schema.ResourceData, meta interface{}) d
 		if err := svc.UpdateDescription(ctx, input); err != nil {
 			return diag.FromErr(err)
 		}
+		changed = true
 	}
 
 	if d.HasChange("app_id_version") || d.HasChange("automatic_upgrade_app_id_version") {
@@ -278,53 +458,47 @@ func updateNgfw(ctx context.Context, d *schema.ResourceData, meta interface{}) d
 		if err := svc.UpdateNGFirewallContentVersion(ctx, input); err != nil {
 			return diag.FromErr(err)
 		}
-	}
-
-	assoc := make([]ngfw.SubnetMapping, 0, len(o.SubnetMappings))
-	disassoc := make([]ngfw.SubnetMapping, 0, len(res.Response.Firewall.SubnetMappings))
-
-	for _, x := range o.SubnetMappings {
-		found := false
-
-		for _, y := range res.Response.Firewall.SubnetMappings {
-			if x.SubnetId == y.SubnetId {
-				found = true
-				break
+		if d.HasChange("app_id_version") && o.AppIdVersion != "" {
+			if diags := waitForNgfwAppIdVersion(ctx, svc, o, d.Timeout(schema.TimeoutUpdate)); diags.HasError() {
+				return diags
+			}
+			if prev, _ := d.GetChange("app_id_version"); prev.(string) != "" {
+				d.Set("previous_app_id_version", prev)
 			}
 		}
-
-		if !found {
-			assoc = append(assoc, ngfw.SubnetMapping{
-				SubnetId: x.SubnetId,
-			})
-		}
+		changed = true
 	}
 
-	for _, x := range res.Response.Firewall.SubnetMappings {
-		found := false
-
-		for _, y := range o.SubnetMappings {
-			if x.SubnetId == y.SubnetId {
-				found = true
-				break
-			}
+	if d.HasChange(RulestackName) || d.HasChange(GlobalRulestackName) {
+		input := ngfw.Info{
+			Name:                o.Name,
+			AccountId:           o.AccountId,
+			RuleStackName:       o.RuleStackName,
+			GlobalRuleStackName: o.GlobalRuleStackName,
 		}
-
-		if !found {
-			disassoc = append(disassoc, ngfw.SubnetMapping{
-				SubnetId: x.SubnetId,
-			})
+		if err := svc.AssociateRuleStack(ctx, input); err != nil {
+			return diag.FromErr(err)
 		}
+		if diags := waitForNgfwRulestack(ctx, svc, o, d.Timeout(schema.TimeoutUpdate)); diags.HasError() {
+			return diags
+		}
+		changed = true
 	}
 
-	if len(assoc) != 0 || len(disassoc) != 0 {
-		if len(assoc) == 0 {
-			assoc = nil
+	if d.HasChange(TagsName) {
+		input := firewall.Info{
+			Firewall:  o.Name,
+			AccountId: o.AccountId,
+			Tags:      o.Tags,
 		}
-		if len(disassoc) == 0 {
-			disassoc = nil
+		if err := firewall.NewClient(con).Apply(ctx, input); err != nil {
+			return diag.FromErr(err)
 		}
+	}
 
+	assoc, disassoc := diffSubnetMappings(o.EndpointMode, o.SubnetMappings, res.Response.Firewall.SubnetMappings)
+
+	if len(assoc) != 0 || len(disassoc) != 0 {
 		input := ngfw.Info{
 			Name:                       o.Name,
 			AccountId:                  o.AccountId,
@@ -334,13 +508,28 @@ func updateNgfw(ctx context.Context, d *schema.ResourceData, meta interface{}) d
 		if err := svc.UpdateSubnetMappings(ctx, input); err != nil {
 			return diag.FromErr(err)
 		}
+		changed = true
+	}
+
+	if changed {
+		if diags := waitForNgfw(ctx, svc, o.AccountId, o.Name, d.Timeout(schema.TimeoutUpdate)); diags.HasError() {
+			return diags
+		}
 	}
 
+	// The planned subnet mapping changes are done.
+	d.Set("associate_subnet_mappings", nil)
+	d.Set("disassociate_subnet_mappings", nil)
+
 	return readNgfw(ctx, d, meta)
 }
 
 func deleteNgfw(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := ngfw.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := ngfw.NewClient(con)
 
 	account_id, name, err := parseNgfwId(d.Id())
 	if err != nil {
@@ -358,18 +547,219 @@ func deleteNgfw(ctx context.Context, d *schema.ResourceData, meta interface{}) d
 		AccountId: account_id,
 	}
 
-	if err = svc.Delete(ctx, fw); err != nil && !isObjectNotFound(err) {
+	if err = svc.Delete(ctx, fw); err != nil {
+		if isObjectNotFound(err) {
+			d.SetId("")
+			return nil
+		}
 		return diag.FromErr(err)
 	}
 
+	if diags := waitForNgfwDeletion(ctx, svc, account_id, name, d.Timeout(schema.TimeoutDelete)); diags.HasError() {
+		return diags
+	}
+
 	d.SetId("")
 	return nil
 }
 
+// Status polling.
+const (
+	ngfwStatusPending = "pending"
+	ngfwStatusReady   = "ready"
+)
+
+// How long to wait before the first status check, and between checks.
+var (
+	ngfwWaitDelay      = 10 * time.Second
+	ngfwWaitMinTimeout = 5 * time.Second
+)
+
+func waitForNgfw(ctx context.Context, svc *ngfw.Client, account_id, name string, timeout time.Duration) diag.Diagnostics {
+	conf := &resource.StateChangeConf{
+		Pending:    []string{ngfwStatusPending},
+		Target:     []string{ngfwStatusReady},
+		Refresh:    ngfwStatusRefreshFunc(ctx, svc, account_id, name),
+		Timeout:    timeout,
+		Delay:      ngfwWaitDelay,
+		MinTimeout: ngfwWaitMinTimeout,
+	}
+
+	if _, err := conf.WaitForStateContext(ctx); err != nil {
+		return diag.Errorf("Error waiting for ngfw %q: %s", name, err)
+	}
+
+	return nil
+}
+
+func waitForNgfwDeletion(ctx context.Context, svc *ngfw.Client, account_id, name string, timeout time.Duration) diag.Diagnostics {
+	conf := &resource.StateChangeConf{
+		Pending:    []string{ngfwStatusPending, ngfwStatusReady},
+		Target:     []string{},
+		Refresh:    ngfwStatusRefreshFunc(ctx, svc, account_id, name),
+		Timeout:    timeout,
+		Delay:      ngfwWaitDelay,
+		MinTimeout: ngfwWaitMinTimeout,
+	}
+
+	if _, err := conf.WaitForStateContext(ctx); err != nil {
+		return diag.Errorf("Error waiting for ngfw %q to be deleted: %s", name, err)
+	}
+
+	return nil
+}
+
+// waitForNgfwConfig waits until the ready func accepts what the firewall
+// reports, describing what is being waited on in errors.
+func waitForNgfwConfig(ctx context.Context, svc *ngfw.Client, o ngfw.Info, what string, timeout time.Duration, ready func(ngfw.ReadResponse) (bool, error)) diag.Diagnostics {
+	conf := &resource.StateChangeConf{
+		Pending: []string{ngfwStatusPending},
+		Target:  []string{ngfwStatusReady},
+		Refresh: func() (interface{}, string, error) {
+			req := ngfw.ReadInput{
+				Name:      o.Name,
+				AccountId: o.AccountId,
+			}
+
+			res, err := svc.Read(ctx, req)
+			if err != nil {
+				return nil, "", err
+			}
+
+			ok, err := ready(*res.Response)
+			if err != nil {
+				return nil, "", err
+			} else if !ok {
+				return res, ngfwStatusPending, nil
+			}
+
+			return res, ngfwStatusReady, nil
+		},
+		Timeout:    timeout,
+		Delay:      ngfwWaitDelay,
+		MinTimeout: ngfwWaitMinTimeout,
+	}
+
+	if _, err := conf.WaitForStateContext(ctx); err != nil {
+		return diag.Errorf("Error waiting for ngfw %q %s: %s", o.Name, what, err)
+	}
+
+	return nil
+}
+
+// waitForNgfwRulestack waits for the firewall to report the rulestacks in the
+// given config, and for its rulestack status to settle.  A firewall without
+// rulestacks may not report a rulestack status at all.
+func waitForNgfwRulestack(ctx context.Context, svc *ngfw.Client, o ngfw.Info, timeout time.Duration) diag.Diagnostics {
+	none := o.RuleStackName == "" && o.GlobalRuleStackName == ""
+
+	return waitForNgfwConfig(ctx, svc, o, "rulestack association", timeout, func(res ngfw.ReadResponse) (bool, error) {
+		var status string
+		if res.Status != nil {
+			status = res.Status.RuleStackStatus
+		} else if !none {
+			return false, nil
+		}
+
+		tflog.Info(
+			ctx, "ngfw rulestack status",
+			"name", o.Name,
+			RulestackName, res.Firewall.RuleStackName,
+			GlobalRulestackName, res.Firewall.GlobalRuleStackName,
+			"rulestack_status", status,
+		)
+
+		if strings.Contains(strings.ToUpper(status), "FAIL") {
+			return false, fmt.Errorf("rulestack status is %s", status)
+		}
+
+		fw := res.Firewall
+		if fw.RuleStackName != o.RuleStackName || fw.GlobalRuleStackName != o.GlobalRuleStackName {
+			return false, nil
+		}
+		return status == "Success" || (none && status == ""), nil
+	})
+}
+
+// waitForNgfwAppIdVersion waits for the firewall to report the App-ID version
+// in the given config.
+func waitForNgfwAppIdVersion(ctx context.Context, svc *ngfw.Client, o ngfw.Info, timeout time.Duration) diag.Diagnostics {
+	return waitForNgfwConfig(ctx, svc, o, "App-ID upgrade", timeout, func(res ngfw.ReadResponse) (bool, error) {
+		tflog.Info(
+			ctx, "ngfw app-id version",
+			"name", o.Name,
+			"app_id_version", res.Firewall.AppIdVersion,
+			"want", o.AppIdVersion,
+		)
+
+		if res.Status != nil && strings.Contains(res.Status.FirewallStatus, "FAIL") {
+			return false, fmt.Errorf("firewall status is %s: %s", res.Status.FirewallStatus, res.Status.FailureReason)
+		}
+
+		return res.Firewall.AppIdVersion == o.AppIdVersion, nil
+	})
+}
+
+// ngfwStatusRefreshFunc collapses the firewall and attachment statuses into
+// either pending or ready.  A nil result means the firewall is gone.
+func ngfwStatusRefreshFunc(ctx context.Context, svc *ngfw.Client, account_id, name string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		req := ngfw.ReadInput{
+			Name:      name,
+			AccountId: account_id,
+		}
+
+		res, err := svc.Read(ctx, req)
+		if err != nil {
+			if isObjectNotFound(err) {
+				return nil, "", nil
+			}
+			return nil, "", err
+		}
+
+		if res.Response.Status == nil {
+			return res, ngfwStatusPending, nil
+		}
+		status := *res.Response.Status
+
+		tflog.Info(
+			ctx, "ngfw status",
+			"name", name,
+			"firewall_status", status.FirewallStatus,
+			"attachments", len(status.Attachments),
+		)
+
+		if strings.Contains(status.FirewallStatus, "FAIL") {
+			return nil, "", fmt.Errorf("firewall status is %s: %s", status.FirewallStatus, status.FailureReason)
+		}
+
+		state := ngfwStatusReady
+		switch status.FirewallStatus {
+		case "CREATING", "UPDATING", "DELETING":
+			state = ngfwStatusPending
+		}
+
+		for _, att := range status.Attachments {
+			switch att.Status {
+			case "FAILED", "REJECTED":
+				return nil, "", fmt.Errorf("attachment %q for subnet %q is %s: %s", att.EndpointId, att.SubnetId, att.Status, att.RejectedReason)
+			case "CREATING", "UPDATING", "DELETING":
+				state = ngfwStatusPending
+			}
+		}
+
+		return res, state, nil
+	}
+}
+
 // Schema handling.
 func ngfwSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 	endpoint_mode_opts := []string{"ServiceManaged", "CustomerManaged"}
 
+	// Rulestack associations are changed in place.
+	rs, grs := rsSchema(), gRsSchema()
+	rs.ForceNew, grs.ForceNew = false, false
+
 	ans := map[string]*schema.Schema{
 		"name": {
 			Type:        schema.TypeString,
@@ -406,6 +796,30 @@ func ngfwSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 			Computed:    true,
 			Description: "The endpoint service name.",
 		},
+		"endpoint_ids": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "The endpoint IDs, keyed by availability zone, or by subnet ID when the availability zone of an endpoint is not known.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"eni_ids": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "The endpoint network interface IDs, keyed by availability zone, or by subnet ID when the availability zone of an endpoint is not known.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"subnet_ids": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "The endpoint subnet IDs, keyed by availability zone, or by subnet ID when the availability zone of an endpoint is not known.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
 		"subnet_mapping": {
 			Type:        schema.TypeList,
 			Required:    true,
@@ -422,30 +836,50 @@ func ngfwSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 						Optional:    true,
 						Description: "The availability zone, for when the endpoint mode is customer managed.",
 					},
-					// Future use
-					// "az_id": {
-					// 	Type:        schema.TypeString,
-					// 	Optional:    true,
-					// 	Description: "The availability zone id.",
-					// },
+					"availability_zone_id": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The availability zone ID, for when the endpoint mode is customer managed.  Use this instead of `availability_zone` when AZ names differ between accounts.",
+					},
 				},
 			},
 		},
+		"associate_subnet_mappings": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The subnet mappings, by subnet ID or availability zone, that the planned update associates.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"disassociate_subnet_mappings": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The subnet mappings, by subnet ID or availability zone, that the planned update disassociates.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
 		"app_id_version": {
 			Type:        schema.TypeString,
 			Computed:    true,
 			Optional:    true,
 			Description: "App-ID version number.",
 		},
+		"previous_app_id_version": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The App-ID version before the most recent upgrade, for rolling it back.",
+		},
 		"automatic_upgrade_app_id_version": {
 			Type:        schema.TypeBool,
 			Optional:    true,
 			Description: "Automatic App-ID upgrade version number.",
 			Default:     true,
 		},
-		RulestackName:       rsSchema(),
-		GlobalRulestackName: gRsSchema(),
-		TagsName:            tagsSchema(true, true),
+		RulestackName:       rs,
+		GlobalRulestackName: grs,
+		TagsName:            tagsSchema(true, false),
 		"update_token": {
 			Type:        schema.TypeString,
 			Computed:    true,
@@ -498,6 +932,16 @@ func ngfwSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 									Computed:    true,
 									Description: "The subnet id.",
 								},
+								"availability_zone": {
+									Type:        schema.TypeString,
+									Computed:    true,
+									Description: "The availability zone.",
+								},
+								"eni_id": {
+									Type:        schema.TypeString,
+									Computed:    true,
+									Description: "The endpoint network interface id.",
+								},
 							},
 						},
 					},
@@ -510,7 +954,12 @@ func ngfwSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 		delete(ans, rmKey)
 	}
 
-	if !isResource {
+	if isResource {
+		// Tags left unset are left alone, so that cloudngfwaws_ngfw_tag can
+		// manage them instead.
+		ans[TagsName].Computed = true
+		ans[TagsName].Description = "The tags. When not set, the tags are left alone, such as for managing them with `cloudngfwaws_ngfw_tag`."
+	} else {
 		computed(ans, "", []string{"name"})
 	}
 
@@ -553,6 +1002,10 @@ func saveNgfw(ctx context.Context, d *schema.ResourceData, name string, o ngfw.R
 		d.Set("status", saveStatus(ctx, *o.Status))
 	}
 
+	endpoints, enis, subnets := saveEndpointsByZone(o)
+	d.Set("endpoint_ids", endpoints)
+	d.Set("eni_ids", enis)
+	d.Set("subnet_ids", subnets)
 }
 
 func saveSubnetMappings(ctx context.Context, subnetMappings []ngfw.SubnetMapping) []interface{} {
@@ -563,7 +1016,7 @@ func saveSubnetMappings(ctx context.Context, subnetMappings []ngfw.SubnetMapping
 			_sm := make(map[string]interface{})
 			_sm["subnet_id"] = sm.SubnetId
 			_sm["availability_zone"] = sm.AvailabilityZone
-			// _sm["az_id"] = sm.AvailabilityZoneId
+			_sm["availability_zone_id"] = sm.AvailabilityZoneId
 			mappings[i] = _sm
 		}
 		return mappings
@@ -579,8 +1032,9 @@ func loadSubnetMappings(ctx context.Context, subnetMappings []interface{}) []ngf
 		for i, sm := range subnetMappings {
 			_smi := sm.(map[string]interface{})
 			_sm := ngfw.SubnetMapping{
-				SubnetId:         _smi["subnet_id"].(string),
-				AvailabilityZone: _smi["availability_zone"].(string),
+				SubnetId:           _smi["subnet_id"].(string),
+				AvailabilityZone:   _smi["availability_zone"].(string),
+				AvailabilityZoneId: _smi["availability_zone_id"].(string),
 			}
 			mappings[i] = _sm
 		}
@@ -591,6 +1045,140 @@ func loadSubnetMappings(ctx context.Context, subnetMappings []interface{}) []ngf
 	return make([]ngfw.SubnetMapping, 0)
 }
 
+// checkSubnetMapping checks that the subnet mapping at the given index has
+// one of the fields that the endpoint mode requires.
+func checkSubnetMapping(d *schema.ResourceDiff, mode string, i int) error {
+	var keys []string
+	switch mode {
+	case "ServiceManaged":
+		keys = []string{"subnet_id"}
+	case "CustomerManaged":
+		keys = []string{"availability_zone", "availability_zone_id"}
+	default:
+		return nil
+	}
+
+	for _, key := range keys {
+		addr := fmt.Sprintf("subnet_mapping.%d.%s", i, key)
+		if !d.NewValueKnown(addr) || d.Get(addr).(string) != "" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("subnet_mapping %d: %s is required when endpoint_mode is %s", i, strings.Join(keys, " or "), mode)
+}
+
+// sameSubnetMapping returns if the wanted subnet mapping is the existing one,
+// comparing subnets for service managed endpoints and availability zones for
+// customer managed ones.
+func sameSubnetMapping(mode string, want, have ngfw.SubnetMapping) bool {
+	if mode != "CustomerManaged" {
+		return want.SubnetId == have.SubnetId
+	}
+
+	if want.AvailabilityZoneId != "" {
+		return want.AvailabilityZoneId == have.AvailabilityZoneId
+	}
+	return want.AvailabilityZone == have.AvailabilityZone
+}
+
+// diffSubnetMappings returns the subnet mappings to associate and to
+// disassociate to get from the existing mappings to the wanted ones.
+func diffSubnetMappings(mode string, want, have []ngfw.SubnetMapping) ([]ngfw.SubnetMapping, []ngfw.SubnetMapping) {
+	var assoc, disassoc []ngfw.SubnetMapping
+
+	for _, x := range want {
+		found := false
+		for _, y := range have {
+			if sameSubnetMapping(mode, x, y) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			assoc = append(assoc, x)
+		}
+	}
+
+	for _, y := range have {
+		found := false
+		for _, x := range want {
+			if sameSubnetMapping(mode, x, y) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			disassoc = append(disassoc, y)
+		}
+	}
+
+	return assoc, disassoc
+}
+
+// subnetMappingNames returns the subnet ID, availability zone ID or
+// availability zone of each subnet mapping.
+func subnetMappingNames(list []ngfw.SubnetMapping) []interface{} {
+	ans := make([]interface{}, 0, len(list))
+	for _, sm := range list {
+		switch {
+		case sm.SubnetId != "":
+			ans = append(ans, sm.SubnetId)
+		case sm.AvailabilityZoneId != "":
+			ans = append(ans, sm.AvailabilityZoneId)
+		default:
+			ans = append(ans, sm.AvailabilityZone)
+		}
+	}
+
+	return ans
+}
+
+// saveEndpointsByZone returns the endpoint, ENI and subnet IDs of the firewall
+// attachments, keyed by availability zone, or by subnet ID for attachments
+// whose availability zone is not known.
+func saveEndpointsByZone(o ngfw.ReadResponse) (map[string]interface{}, map[string]interface{}, map[string]interface{}) {
+	endpoints := make(map[string]interface{})
+	enis := make(map[string]interface{})
+	subnets := make(map[string]interface{})
+
+	if o.Status == nil {
+		return endpoints, enis, subnets
+	}
+
+	for _, att := range o.Status.Attachments {
+		az := att.AvailabilityZone
+		if az == "" {
+			for _, sm := range o.Firewall.SubnetMappings {
+				if sm.SubnetId != "" && sm.SubnetId == att.SubnetId {
+					az = sm.AvailabilityZone
+					break
+				}
+			}
+		}
+		if az == "" {
+			// Attachments that are not in a known availability zone are
+			// keyed by their subnet instead.
+			az = att.SubnetId
+		}
+		if az == "" {
+			continue
+		}
+
+		if att.EndpointId != "" {
+			endpoints[az] = att.EndpointId
+		}
+		if att.EniId != "" {
+			enis[az] = att.EniId
+		}
+		if att.SubnetId != "" {
+			subnets[az] = att.SubnetId
+		}
+	}
+
+	return endpoints, enis, subnets
+}
+
 func saveStatus(ctx context.Context, status ngfw.FirewallStatus) []interface{} {
 
 	s := make([]interface{}, 1, 1)
@@ -603,6 +1191,8 @@ func saveStatus(ctx context.Context, status ngfw.FirewallStatus) []interface{} {
 		_att["status"] = att.Status
 		_att["rejected_reason"] = att.RejectedReason
 		_att["subnet_id"] = att.SubnetId
+		_att["availability_zone"] = att.AvailabilityZone
+		_att["eni_id"] = att.EniId
 		attachments[i] = _att
 	}
 
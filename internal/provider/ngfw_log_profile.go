@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	lp "github.com/paloaltonetworks/cloud-ngfw-aws-go/firewall/logprofile"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -26,7 +25,11 @@ func dataSourceNgfwLogProfile() *schema.Resource {
 }
 
 func readNgfwLogProfileDataSource(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := lp.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := lp.NewClient(con)
 
 	aid := d.Get("account_id").(string)
 	ngfw := d.Get("ngfw").(string)
@@ -78,7 +81,11 @@ func resourceNgfwLogProfile() *schema.Resource {
 }
 
 func createUpdateNgfwLogProfile(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := lp.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := lp.NewClient(con)
 	o := loadNgfwLogProfile(d)
 
 	tflog.Info(
@@ -97,7 +104,11 @@ func createUpdateNgfwLogProfile(ctx context.Context, d *schema.ResourceData, met
 }
 
 func readNgfwLogProfile(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := lp.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := lp.NewClient(con)
 	aid, ngfw, err := parseNgfwLogProfileId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
@@ -26,7 +26,11 @@ func dataSourceNgfwTag() *schema.Resource {
 }
 
 func readNgfwTagDataSource(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := firewall.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := firewall.NewClient(con)
 
 	req := firewall.ListInput{
 		Firewall:   d.Get("ngfw").(string),
@@ -43,7 +47,7 @@ func readNgfwTagDataSource(ctx context.Context, d *schema.ResourceData, meta int
 		"account_id", req.AccountId,
 	)
 
-	res, err := svc.List(ctx, req)
+	tags, err := listNgfwTags(ctx, svc, req)
 	if err != nil {
 		if isObjectNotFound(err) {
 			d.SetId("")
@@ -54,7 +58,7 @@ func readNgfwTagDataSource(ctx context.Context, d *schema.ResourceData, meta int
 
 	d.SetId(id)
 
-	saveNgfwTag(d, req.Firewall, req.AccountId, res.Response.Tags)
+	saveNgfwTag(d, req.Firewall, req.AccountId, tags)
 
 	return nil
 }
@@ -62,7 +66,7 @@ func readNgfwTagDataSource(ctx context.Context, d *schema.ResourceData, meta int
 // Resource.
 func resourceNgfwTag() *schema.Resource {
 	return &schema.Resource{
-		Description: "Resource for NGFW tag manipulation.",
+		Description: "Resource for NGFW tag manipulation.  Leave `tags` unset on the `cloudngfwaws_ngfw` of an NGFW whose tags this resource manages, as the two would otherwise overwrite each other's tags.",
 
 		CreateContext: createUpdateNgfwTag,
 		ReadContext:   readNgfwTag,
@@ -78,7 +82,11 @@ func resourceNgfwTag() *schema.Resource {
 }
 
 func createUpdateNgfwTag(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := firewall.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := firewall.NewClient(con)
 	o := loadNgfwTag(d)
 	tflog.Info(
 		ctx, "modify ngfw tags",
@@ -96,7 +104,11 @@ func createUpdateNgfwTag(ctx context.Context, d *schema.ResourceData, meta inter
 }
 
 func readNgfwTag(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := firewall.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := firewall.NewClient(con)
 
 	aid, ngfw, err := parseNgfwTagId(d.Id())
 	if err != nil {
@@ -106,7 +118,7 @@ func readNgfwTag(ctx context.Context, d *schema.ResourceData, meta interface{})
 	req := firewall.ListInput{
 		Firewall:   ngfw,
 		AccountId:  aid,
-		MaxResults: 100,
+		MaxResults: 1000,
 	}
 
 	tflog.Info(
@@ -115,7 +127,7 @@ func readNgfwTag(ctx context.Context, d *schema.ResourceData, meta interface{})
 		"account_id", req.AccountId,
 	)
 
-	res, err := svc.List(ctx, req)
+	tags, err := listNgfwTags(ctx, svc, req)
 	if err != nil {
 		if isObjectNotFound(err) {
 			d.SetId("")
@@ -124,13 +136,17 @@ func readNgfwTag(ctx context.Context, d *schema.ResourceData, meta interface{})
 		return diag.FromErr(err)
 	}
 
-	saveNgfwTag(d, req.Firewall, req.AccountId, res.Response.Tags)
+	saveNgfwTag(d, req.Firewall, req.AccountId, tags)
 
-	return nil
+	return claimNgfwTags(meta, con, aid, ngfw, ngfwTagsStandalone)
 }
 
 func deleteNgfwTag(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := firewall.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := firewall.NewClient(con)
 
 	aid, ngfw, err := parseNgfwTagId(d.Id())
 	if err != nil {
@@ -151,6 +167,71 @@ func deleteNgfwTag(ctx context.Context, d *schema.ResourceData, meta interface{}
 	return nil
 }
 
+// listNgfwTags returns every tag, following pagination.
+func listNgfwTags(ctx context.Context, svc *firewall.Client, req firewall.ListInput) ([]tag.Details, error) {
+	var ans []tag.Details
+
+	_, err := readPages("", true, 0, func(token string) (int, string, error) {
+		req.NextToken = token
+		res, err := svc.List(ctx, req)
+		if err != nil {
+			return 0, "", err
+		}
+
+		ans = append(ans, res.Response.Tags...)
+		return len(res.Response.Tags), res.Response.NextToken, nil
+	})
+
+	return ans, err
+}
+
+// Tag ownership.
+const (
+	ngfwTagsInline     = "cloudngfwaws_ngfw"
+	ngfwTagsStandalone = "cloudngfwaws_ngfw_tag"
+)
+
+// ngfwTagKey is a firewall, along with the client that manages it.
+type ngfwTagKey struct {
+	con       *awsngfw.Client
+	accountId string
+	name      string
+}
+
+// claimNgfwTags records that the given resource type manages the tags of the
+// firewall, returning a warning the first time both the inline tags of
+// cloudngfwaws_ngfw and cloudngfwaws_ngfw_tag are seen for the same firewall.
+func claimNgfwTags(meta interface{}, con *awsngfw.Client, aid, name, owner string) diag.Diagnostics {
+	p := meta.(*providerMeta)
+	key := ngfwTagKey{con, aid, name}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ngfwTags == nil {
+		p.ngfwTags = make(map[ngfwTagKey]map[string]bool)
+	}
+	owners := p.ngfwTags[key]
+	if owners == nil {
+		owners = make(map[string]bool)
+		p.ngfwTags[key] = owners
+	}
+	if owners[owner] {
+		return nil
+	}
+	owners[owner] = true
+
+	if !owners[ngfwTagsInline] || !owners[ngfwTagsStandalone] {
+		return nil
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  fmt.Sprintf("Tags of ngfw %q are managed by both %s and %s", name, ngfwTagsInline, ngfwTagsStandalone),
+		Detail:   fmt.Sprintf("Each resource replaces the tags set by the other, so they will never converge. Manage the tags with either the tags param of %s or with %s, not both.", ngfwTagsInline, ngfwTagsStandalone),
+	}}
+}
+
 // Schema handling.
 func ngfwTagSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 	ans := map[string]*schema.Schema{
@@ -0,0 +1,436 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	ngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/firewall"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// Resource.
+func TestResourceNgfwOffline(t *testing.T) {
+	testFastNgfwWaits(t)
+
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	m.FirewallTransitionReads = 3
+	l := newTestLifecycle(t, resourceNgfw(), m.meta())
+
+	raw := map[string]interface{}{
+		"name":          "fw",
+		"vpc_id":        "vpc-1234",
+		"account_id":    mockAccountId,
+		"description":   "first",
+		"endpoint_mode": "ServiceManaged",
+		"rulestack":     "rs",
+		"subnet_mapping": []interface{}{
+			map[string]interface{}{
+				"subnet_id": "subnet-1",
+			},
+		},
+	}
+
+	l.apply(raw)
+	l.check(map[string]string{
+		"id":          buildNgfwId(mockAccountId, "fw"),
+		"description": "first",
+	})
+	if status := m.Firewall("fw").Status; status != "CREATE_COMPLETE" {
+		t.Errorf("Create returned while the firewall status is %s", status)
+	}
+
+	raw["description"] = "second"
+	l.apply(raw)
+	l.check(map[string]string{
+		"description": "second",
+	})
+	if status := m.Firewall("fw").Status; status != "UPDATE_COMPLETE" {
+		t.Errorf("Update returned while the firewall status is %s", status)
+	}
+
+	l.destroy()
+	if fw := m.Firewall("fw"); !fw.Deleted || fw.reads < m.FirewallTransitionReads {
+		t.Errorf("Delete returned before the firewall was gone")
+	}
+}
+
+func TestResourceNgfwRulestackOffline(t *testing.T) {
+	testFastNgfwWaits(t)
+
+	m := newMockApi(t)
+	m.addRulestack("rs1")
+	m.addRulestack("rs2")
+	l := newTestLifecycle(t, resourceNgfw(), m.meta())
+
+	raw := map[string]interface{}{
+		"name":          "fw",
+		"vpc_id":        "vpc-1234",
+		"account_id":    mockAccountId,
+		"endpoint_mode": "ServiceManaged",
+		"rulestack":     "rs1",
+		"subnet_mapping": []interface{}{
+			map[string]interface{}{
+				"subnet_id": "subnet-1",
+			},
+		},
+	}
+
+	l.apply(raw)
+	fw := m.Firewall("fw")
+
+	raw["rulestack"] = "rs2"
+	l.apply(raw)
+	l.check(map[string]string{
+		"rulestack":                 "rs2",
+		"status.0.rulestack_status": "Success",
+	})
+	if m.Firewall("fw") != fw {
+		t.Errorf("Firewall was recreated to change its rulestack")
+	}
+	if status := m.Firewall("fw").RulestackStatus; status != "Success" {
+		t.Errorf("Update returned while the rulestack status is %s", status)
+	}
+
+	// Without a rulestack there is no rulestack status to wait for.
+	delete(raw, "rulestack")
+	l.apply(raw)
+	l.check(map[string]string{
+		"rulestack":                 "",
+		"status.0.rulestack_status": "",
+	})
+	if status := m.Firewall("fw").RulestackStatus; status != "" {
+		t.Errorf("Got rulestack status %q without a rulestack", status)
+	}
+}
+
+func TestResourceNgfwTagsOffline(t *testing.T) {
+	testFastNgfwWaits(t)
+
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	meta := m.meta()
+	l := newTestLifecycle(t, resourceNgfw(), meta)
+
+	raw := map[string]interface{}{
+		"name":          "fw",
+		"vpc_id":        "vpc-1234",
+		"account_id":    mockAccountId,
+		"endpoint_mode": "ServiceManaged",
+		"rulestack":     "rs",
+		"subnet_mapping": []interface{}{
+			map[string]interface{}{
+				"subnet_id": "subnet-1",
+			},
+		},
+		"tags": map[string]interface{}{
+			"env": "dev",
+		},
+	}
+
+	l.apply(raw)
+	fw := m.Firewall("fw")
+
+	raw["tags"] = map[string]interface{}{
+		"env":         "prod",
+		"cost_center": "42",
+	}
+	l.apply(raw)
+	l.check(map[string]string{
+		"tags.%":           "2",
+		"tags.env":         "prod",
+		"tags.cost_center": "42",
+	})
+	if m.Firewall("fw") != fw {
+		t.Errorf("Firewall was recreated to change its tags")
+	}
+
+	// The standalone tag resource on the same firewall is warned about.
+	tl := newTestLifecycle(t, resourceNgfwTag(), meta)
+	diags := tl.applyDiags(map[string]interface{}{
+		"ngfw":       "fw",
+		"account_id": mockAccountId,
+		"tags": map[string]interface{}{
+			"owner": "network",
+		},
+	})
+	if diags.HasError() {
+		t.Fatalf("Error applying ngfw tags: %s", diagsToString(diags))
+	}
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Errorf("Expected a warning about tags managed twice, got: %s", diagsToString(diags))
+	}
+}
+
+func TestResourceNgfwWithoutTagsOffline(t *testing.T) {
+	testFastNgfwWaits(t)
+
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	meta := m.meta()
+	l := newTestLifecycle(t, resourceNgfw(), meta)
+
+	raw := map[string]interface{}{
+		"name":          "fw",
+		"vpc_id":        "vpc-1234",
+		"account_id":    mockAccountId,
+		"endpoint_mode": "ServiceManaged",
+		"rulestack":     "rs",
+		"subnet_mapping": []interface{}{
+			map[string]interface{}{
+				"subnet_id": "subnet-1",
+			},
+		},
+	}
+	l.apply(raw)
+
+	// A firewall without tags leaves them to the standalone tag resource.
+	tl := newTestLifecycle(t, resourceNgfwTag(), meta)
+	diags := tl.applyDiags(map[string]interface{}{
+		"ngfw":       "fw",
+		"account_id": mockAccountId,
+		"tags": map[string]interface{}{
+			"owner": "network",
+		},
+	})
+	if len(diags) != 0 {
+		t.Errorf("Applying ngfw tags returned diagnostics: %s", diagsToString(diags))
+	}
+
+	if diags := l.refresh(); diags.HasError() {
+		t.Fatalf("Error refreshing: %s", diagsToString(diags))
+	}
+	raw["description"] = "updated"
+	l.apply(raw)
+	l.check(map[string]string{
+		"tags.%":     "1",
+		"tags.owner": "network",
+	})
+	if v := m.Firewall("fw").Tags["owner"]; v != "network" {
+		t.Errorf("Firewall tags were replaced, owner is %q", v)
+	}
+}
+
+func TestResourceNgfwEndpointsOffline(t *testing.T) {
+	testFastNgfwWaits(t)
+
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	l := newTestLifecycle(t, resourceNgfw(), m.meta())
+
+	raw := map[string]interface{}{
+		"name":          "fw",
+		"vpc_id":        "vpc-1234",
+		"account_id":    mockAccountId,
+		"endpoint_mode": "ServiceManaged",
+		"rulestack":     "rs",
+		"subnet_mapping": []interface{}{
+			map[string]interface{}{
+				"subnet_id": "subnet-1",
+			},
+			map[string]interface{}{
+				"subnet_id": "subnet-2",
+			},
+		},
+	}
+
+	l.apply(raw)
+	l.check(map[string]string{
+		"endpoint_ids.%":          "2",
+		"endpoint_ids.us-east-1a": "vpce-00000000",
+		"eni_ids.us-east-1b":      "eni-00000001",
+		"subnet_ids.us-east-1b":   "subnet-2",
+	})
+
+	// Endpoints in an unknown availability zone are keyed by subnet.
+	m.Firewall("fw").NoAttachmentZones = true
+	if diags := l.refresh(); diags.HasError() {
+		t.Fatalf("Error refreshing: %s", diagsToString(diags))
+	}
+	l.check(map[string]string{
+		"endpoint_ids.%":        "2",
+		"endpoint_ids.subnet-1": "vpce-00000000",
+		"eni_ids.subnet-2":      "eni-00000001",
+		"subnet_ids.subnet-2":   "subnet-2",
+	})
+}
+
+func TestResourceNgfwSubnetMappingsOffline(t *testing.T) {
+	testFastNgfwWaits(t)
+
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	meta := m.meta()
+	r := resourceNgfw()
+	l := newTestLifecycle(t, r, meta)
+
+	zones := func(ids ...string) []interface{} {
+		list := make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			list = append(list, map[string]interface{}{
+				"availability_zone_id": id,
+			})
+		}
+		return list
+	}
+
+	raw := map[string]interface{}{
+		"name":           "fw",
+		"vpc_id":         "vpc-1234",
+		"account_id":     mockAccountId,
+		"endpoint_mode":  "CustomerManaged",
+		"rulestack":      "rs",
+		"subnet_mapping": zones("use1-az1", "use1-az2"),
+	}
+
+	l.apply(raw)
+
+	// The plan shows the mappings by AZ ID, regardless of their order.
+	raw["subnet_mapping"] = zones("use1-az2", "use1-az4")
+	diff, err := r.Diff(context.Background(), l.state, terraform.NewResourceConfigRaw(raw), meta)
+	if err != nil {
+		t.Fatalf("Error planning: %s", err)
+	}
+	for key, value := range map[string]string{
+		"associate_subnet_mappings.#":    "1",
+		"associate_subnet_mappings.0":    "use1-az4",
+		"disassociate_subnet_mappings.#": "1",
+		"disassociate_subnet_mappings.0": "use1-az1",
+	} {
+		if attr := diff.Attributes[key]; attr == nil || attr.New != value {
+			t.Errorf("Plan %s: got %#v, expected %q", key, attr, value)
+		}
+	}
+
+	l.apply(raw)
+	l.check(map[string]string{
+		"subnet_mapping.#":               "2",
+		"associate_subnet_mappings.#":    "0",
+		"disassociate_subnet_mappings.#": "0",
+	})
+
+	var got []string
+	mappings, _ := m.Firewall("fw").Firewall["SubnetMappings"].([]interface{})
+	for _, x := range mappings {
+		got = append(got, subnetKey(x))
+	}
+	if strings.Join(got, ",") != "use1-az2,use1-az4" {
+		t.Errorf("Firewall has subnet mappings %v", got)
+	}
+}
+
+func TestResourceNgfwAppIdVersionOffline(t *testing.T) {
+	testFastNgfwWaits(t)
+
+	m := newMockApi(t)
+	m.FirewallTransitionReads = 3
+	m.AppIdVersions["8400-6900"] = nil
+	m.AppIdVersions["8500-7000"] = nil
+	m.AppIdVersions["8509-7158"] = nil
+	rs := m.addRulestack("rs")
+	rs.Running["MinAppIdVersion"] = "8500-7000"
+	l := newTestLifecycle(t, resourceNgfw(), m.meta())
+
+	raw := map[string]interface{}{
+		"name":                             "fw",
+		"vpc_id":                           "vpc-1234",
+		"account_id":                       mockAccountId,
+		"endpoint_mode":                    "ServiceManaged",
+		"rulestack":                        "rs",
+		"app_id_version":                   "8500-7000",
+		"automatic_upgrade_app_id_version": false,
+		"subnet_mapping": []interface{}{
+			map[string]interface{}{
+				"subnet_id": "subnet-1",
+			},
+		},
+	}
+
+	l.apply(raw)
+
+	raw["app_id_version"] = "8509-7158"
+	l.apply(raw)
+	l.check(map[string]string{
+		"app_id_version":          "8509-7158",
+		"previous_app_id_version": "8500-7000",
+	})
+	if v := m.Firewall("fw").Firewall["AppIdVersion"]; v != "8509-7158" {
+		t.Errorf("Update returned while the firewall has App-ID version %v", v)
+	}
+
+	for version, msg := range map[string]string{
+		"9999-1":    "not an available App-ID version",
+		"8400-6900": "below the minimum_app_id_version",
+	} {
+		raw["app_id_version"] = version
+		diags := l.applyDiags(raw)
+		if !diags.HasError() {
+			t.Errorf("%s: version was accepted", version)
+		} else if got := diagsToString(diags); !strings.Contains(got, msg) {
+			t.Errorf("%s: got error: %s", version, got)
+		}
+	}
+}
+
+func TestResourceNgfwSubnetMappingValidation(t *testing.T) {
+	m := newMockApi(t)
+
+	// Each mode is given only the field the other mode needs.
+	wrong := map[string]map[string]interface{}{
+		"ServiceManaged":  {"availability_zone": "us-east-1a"},
+		"CustomerManaged": {"subnet_id": "subnet-1"},
+	}
+
+	for mode, mapping := range wrong {
+		l := newTestLifecycle(t, resourceNgfw(), m.meta())
+		diags := l.applyDiags(map[string]interface{}{
+			"name":           "fw",
+			"vpc_id":         "vpc-1234",
+			"endpoint_mode":  mode,
+			"rulestack":      "rs",
+			"subnet_mapping": []interface{}{mapping},
+		})
+		if !diags.HasError() {
+			t.Errorf("%s: empty subnet mapping was accepted", mode)
+		} else if msg := diagsToString(diags); !strings.Contains(msg, "required when endpoint_mode is "+mode) {
+			t.Errorf("%s: got error: %s", mode, msg)
+		}
+	}
+}
+
+func TestWaitForNgfwFailure(t *testing.T) {
+	testFastNgfwWaits(t)
+
+	m := newMockApi(t)
+	m.firewalls["fw"] = &mockFirewall{
+		AccountId: mockAccountId,
+		Firewall: map[string]interface{}{
+			"FirewallName": "fw",
+			"AccountId":    mockAccountId,
+		},
+		Status: "CREATE_FAILED",
+	}
+
+	svc := ngfw.NewClient(m.client())
+	diags := waitForNgfw(context.Background(), svc, mockAccountId, "fw", time.Minute)
+	if !diags.HasError() {
+		t.Fatalf("Waiting on a failed firewall did not return an error")
+	}
+	if msg := diagsToString(diags); !strings.Contains(msg, "CREATE_FAILED") {
+		t.Errorf("Error does not include the firewall status: %s", msg)
+	}
+}
+
+// testFastNgfwWaits shortens firewall status polling for the test.
+func testFastNgfwWaits(t *testing.T) {
+	delay, minTimeout := ngfwWaitDelay, ngfwWaitMinTimeout
+	ngfwWaitDelay, ngfwWaitMinTimeout = 10*time.Millisecond, 10*time.Millisecond
+
+	t.Cleanup(func() {
+		ngfwWaitDelay, ngfwWaitMinTimeout = delay, minTimeout
+	})
+}
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Bounds for the delay between status checks in pollStatus.
+const (
+	pollMinDelay = 1 * time.Second
+	pollMaxDelay = 30 * time.Second
+)
+
+// pollStatus invokes check until it reports that it is done, check returns
+// an error, or ctx is done.  The delay between checks grows exponentially,
+// with jitter, from pollMinDelay up to pollMaxDelay.
+//
+// The last status seen is always returned; if ctx expired first, the error
+// includes it as well.
+func pollStatus(ctx context.Context, desc string, check func() (string, bool, error)) (string, error) {
+	var last string
+	delay := pollMinDelay
+
+	for attempt := 1; ; attempt++ {
+		status, done, err := check()
+		if err != nil {
+			if ctx.Err() != nil {
+				return last, pollTimeoutError(desc, last, ctx.Err())
+			}
+			return last, err
+		}
+		last = status
+
+		if done {
+			return last, nil
+		}
+
+		wait := delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+		tflog.Info(
+			ctx, "waiting on status",
+			"desc", desc,
+			"status", status,
+			"attempt", attempt,
+			"wait", wait.String(),
+		)
+
+		select {
+		case <-ctx.Done():
+			return last, pollTimeoutError(desc, last, ctx.Err())
+		case <-time.After(wait):
+		}
+
+		delay *= 2
+		if delay > pollMaxDelay {
+			delay = pollMaxDelay
+		}
+	}
+}
+
+func pollTimeoutError(desc, last string, err error) error {
+	return fmt.Errorf("Timed out waiting for %s (last status: %q): %s", desc, last, err)
+}
@@ -6,7 +6,6 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/predefined/url"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -28,6 +27,8 @@ func dataSourcePredefinedUrlCategories() *schema.Resource {
 				Optional:    true,
 				Description: "Pagination token.",
 			},
+			"all_pages":         allPagesSchema(),
+			"max_total_results": maxTotalResultsSchema(),
 			"max_results": {
 				Type:        schema.TypeInt,
 				Optional:    true,
@@ -52,7 +53,11 @@ func dataSourcePredefinedUrlCategories() *schema.Resource {
 }
 
 func readPredefinedUrlCategories(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := url.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := url.NewClient(con)
 
 	input := url.ListInput{
 		NextToken:  d.Get("token").(string),
@@ -69,16 +74,26 @@ func readPredefinedUrlCategories(ctx context.Context, d *schema.ResourceData, me
 		"max_results", input.MaxResults,
 	)
 
-	ans, err := svc.List(ctx, input)
+	var categories []string
+	next, err := readPages(input.NextToken, d.Get("all_pages").(bool), d.Get("max_total_results").(int), func(token string) (int, string, error) {
+		input.NextToken = token
+		ans, err := svc.List(ctx, input)
+		if err != nil {
+			return 0, "", err
+		}
+
+		categories = append(categories, ans.Response.Categories...)
+		return len(ans.Response.Categories), ans.Response.NextToken, nil
+	})
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
 	d.SetId(strings.Join(
-		[]string{input.NextToken, strconv.Itoa(input.MaxResults)}, IdSeparator,
+		[]string{d.Get("token").(string), strconv.Itoa(input.MaxResults)}, IdSeparator,
 	))
-	d.Set("next_token", ans.Response.NextToken)
-	d.Set("categories", ans.Response.Categories)
+	d.Set("next_token", next)
+	d.Set("categories", categories)
 
 	return nil
 }
@@ -95,7 +110,11 @@ func dataSourcePredefinedUrlCategoryOverride() *schema.Resource {
 }
 
 func readDataSourcePredefinedUrlCategoryOverride(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := url.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := url.NewClient(con)
 
 	input := url.GetOverrideInput{
 		Rulestack: d.Get(RulestackName).(string),
@@ -154,6 +173,8 @@ func resourcePredefinedUrlCategoryOverride() *schema.Resource {
 		UpdateContext: createUpdatePredefinedUrlCategoryOverride,
 		DeleteContext: deletePredefinedUrlCategoryOverride,
 
+		CustomizeDiff: customizeDiffAutoCommit(RulestackName),
+
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
@@ -163,7 +184,11 @@ func resourcePredefinedUrlCategoryOverride() *schema.Resource {
 }
 
 func createUpdatePredefinedUrlCategoryOverride(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := url.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := url.NewClient(con)
 
 	input := url.OverrideInput{
 		Rulestack:    d.Get(RulestackName).(string),
@@ -191,7 +216,11 @@ func createUpdatePredefinedUrlCategoryOverride(ctx context.Context, d *schema.Re
 }
 
 func readPredefinedUrlCategoryOverride(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := url.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := url.NewClient(con)
 
 	stack, name, err := parsePredefinedUrlCategoryOverrideId(d.Id())
 	if err != nil {
@@ -226,7 +255,11 @@ func readPredefinedUrlCategoryOverride(ctx context.Context, d *schema.ResourceDa
 }
 
 func deletePredefinedUrlCategoryOverride(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := url.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := url.NewClient(con)
 
 	stack, name, err := parsePredefinedUrlCategoryOverrideId(d.Id())
 	if err != nil {
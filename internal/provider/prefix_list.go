@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/object/prefix"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -25,7 +24,11 @@ func dataSourcePrefixList() *schema.Resource {
 }
 
 func readPrefixListDataSource(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := prefix.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := prefix.NewClient(con)
 
 	style := d.Get(ConfigTypeName).(string)
 	d.Set(ConfigTypeName, style)
@@ -77,6 +80,85 @@ func readPrefixListDataSource(ctx context.Context, d *schema.ResourceData, meta
 	return nil
 }
 
+// Data source (list).
+func dataSourcePrefixLists() *schema.Resource {
+	return &schema.Resource{
+		Description: "Data source for retrieving every prefix list in a rulestack.",
+
+		ReadContext: readPrefixLists,
+
+		Schema: objectListSchema(
+			"prefix_lists", "The prefix lists.",
+			prefixListSchema(false, []string{ConfigTypeName, RulestackName}),
+		),
+	}
+}
+
+func readPrefixLists(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := prefix.NewClient(con)
+
+	style := d.Get(ConfigTypeName).(string)
+	d.Set(ConfigTypeName, style)
+
+	stack := d.Get(RulestackName).(string)
+
+	tflog.Info(
+		ctx, "read prefix lists",
+		"ds", true,
+		ConfigTypeName, style,
+		RulestackName, stack,
+	)
+
+	list, err := listPrefixListNames(ctx, svc, stack, style)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	names := make([]string, 0, len(list))
+	items := make([]interface{}, 0, len(list))
+	for _, name := range list {
+		req := prefix.ReadInput{
+			Rulestack: stack,
+			Name:      name,
+		}
+		switch style {
+		case CandidateConfig:
+			req.Candidate = true
+		case RunningConfig:
+			req.Running = true
+		}
+
+		res, err := svc.Read(ctx, req)
+		if err != nil {
+			if isObjectNotFound(err) {
+				continue
+			}
+			return diag.FromErr(err)
+		}
+
+		info := res.Response.Candidate
+		if style == RunningConfig {
+			info = res.Response.Running
+		}
+		if info == nil {
+			continue
+		}
+
+		names = append(names, name)
+		items = append(items, dumpPrefixList(name, *info))
+	}
+
+	d.SetId(configTypeId(style, stack))
+	d.Set("names", names)
+	d.Set("prefix_lists", items)
+
+	return nil
+}
+
 // Resource.
 func resourcePrefixList() *schema.Resource {
 	return &schema.Resource{
@@ -87,16 +169,22 @@ func resourcePrefixList() *schema.Resource {
 		UpdateContext: updatePrefixList,
 		DeleteContext: deletePrefixList,
 
+		CustomizeDiff: customizeDiffAutoCommit(RulestackName),
+
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
 
-		Schema: prefixListSchema(true, []string{ConfigTypeName}),
+		Schema: runningDiffSchema(prefixListSchema(true, []string{ConfigTypeName})),
 	}
 }
 
 func createPrefixList(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := prefix.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := prefix.NewClient(con)
 	o := loadPrefixList(d)
 	tflog.Info(
 		ctx, "create prefix list",
@@ -114,39 +202,51 @@ func createPrefixList(ctx context.Context, d *schema.ResourceData, meta interfac
 }
 
 func readPrefixList(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := prefix.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := prefix.NewClient(con)
 	stack, name, err := parsePrefixListId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
 	}
 
-	req := prefix.ReadInput{
-		Rulestack: stack,
-		Name:      name,
-		Candidate: true,
-	}
 	tflog.Info(
 		ctx, "read prefix list",
-		RulestackName, req.Rulestack,
+		RulestackName, stack,
 		"name", name,
 	)
 
-	res, err := svc.Read(ctx, req)
+	candidate, running, err := readPrefixListConfigs(ctx, svc, stack, name)
 	if err != nil {
 		if isObjectNotFound(err) {
 			d.SetId("")
 			return nil
 		}
 		return diag.FromErr(err)
+	} else if candidate == nil {
+		d.SetId("")
+		return nil
 	}
 
-	savePrefixList(d, stack, name, *res.Response.Candidate)
+	savePrefixList(d, stack, name, *candidate)
+
+	var rdump map[string]interface{}
+	if running != nil {
+		rdump = dumpPrefixList(name, *running)
+	}
+	saveRunningDiff(d, dumpPrefixList(name, *candidate), rdump)
 
 	return nil
 }
 
 func updatePrefixList(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := prefix.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := prefix.NewClient(con)
 	o := loadPrefixList(d)
 	tflog.Info(
 		ctx, "update prefix list",
@@ -162,7 +262,11 @@ func updatePrefixList(ctx context.Context, d *schema.ResourceData, meta interfac
 }
 
 func deletePrefixList(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := prefix.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := prefix.NewClient(con)
 	stack, name, err := parsePrefixListId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
@@ -182,6 +286,52 @@ func deletePrefixList(ctx context.Context, d *schema.ResourceData, meta interfac
 	return nil
 }
 
+// listPrefixListNames returns the name of every prefix list in the given
+// config.
+func listPrefixListNames(ctx context.Context, svc *prefix.Client, stack, style string) ([]string, error) {
+	return listAll(func(token string) ([]string, string, error) {
+		input := prefix.ListInput{
+			Rulestack:  stack,
+			NextToken:  token,
+			MaxResults: 100,
+		}
+		switch style {
+		case CandidateConfig:
+			input.Candidate = true
+		case RunningConfig:
+			input.Running = true
+		}
+
+		res, err := svc.List(ctx, input)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if style == RunningConfig {
+			return res.Response.Running, res.Response.NextToken, nil
+		}
+		return res.Response.Candidate, res.Response.NextToken, nil
+	})
+}
+
+// readPrefixListConfigs returns the candidate and running config of a prefix
+// list, either of which is nil if it is absent from that config.
+func readPrefixListConfigs(ctx context.Context, svc *prefix.Client, stack, name string) (*prefix.Info, *prefix.Info, error) {
+	req := prefix.ReadInput{
+		Rulestack: stack,
+		Name:      name,
+		Candidate: true,
+		Running:   true,
+	}
+
+	res, err := svc.Read(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res.Response.Candidate, res.Response.Running, nil
+}
+
 // Schema handling.
 func prefixListSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 	ans := map[string]*schema.Schema{
@@ -242,11 +392,19 @@ func loadPrefixList(d *schema.ResourceData) prefix.Info {
 
 func savePrefixList(d *schema.ResourceData, stack, name string, o prefix.Info) {
 	d.Set(RulestackName, stack)
-	d.Set("name", name)
-	d.Set("description", o.Description)
-	d.Set("prefix_list", sliceToSet(o.PrefixList))
-	d.Set("audit_comment", o.AuditComment)
-	d.Set("update_token", o.UpdateToken)
+	for key, value := range dumpPrefixList(name, o) {
+		d.Set(key, value)
+	}
+}
+
+func dumpPrefixList(name string, o prefix.Info) map[string]interface{} {
+	return map[string]interface{}{
+		"name":          name,
+		"description":   o.Description,
+		"prefix_list":   sliceToSet(o.PrefixList),
+		"audit_comment": o.AuditComment,
+		"update_token":  o.UpdateToken,
+	}
 }
 
 // Id functions.
@@ -40,6 +40,50 @@ func TestAccDataSourcePrefixList(t *testing.T) {
 	})
 }
 
+func TestDataSourcePrefixListsOffline(t *testing.T) {
+	m := newMockApi(t)
+	rs := m.addRulestack("rs")
+
+	for _, name := range []string{"pl1", "pl2"} {
+		l := newTestLifecycle(t, resourcePrefixList(), m.meta())
+		l.apply(map[string]interface{}{
+			RulestackName: "rs",
+			"name":        name,
+			"description": "desc " + name,
+			"prefix_list": []interface{}{"10.0.0.0/8"},
+		})
+		if name == "pl1" {
+			m.commit(rs)
+		}
+	}
+
+	ds := testReadDataSource(t, dataSourcePrefixLists(), m.meta(), map[string]interface{}{
+		RulestackName: "rs",
+	})
+	want := map[string]string{
+		"names.#":                      "2",
+		"names.0":                      "pl1",
+		"names.1":                      "pl2",
+		"prefix_lists.#":               "2",
+		"prefix_lists.1.name":          "pl2",
+		"prefix_lists.1.description":   "desc pl2",
+		"prefix_lists.1.prefix_list.#": "1",
+	}
+	for key, value := range want {
+		if ds[key] != value {
+			t.Errorf("candidate %s: got %q, expected %q", key, ds[key], value)
+		}
+	}
+
+	ds = testReadDataSource(t, dataSourcePrefixLists(), m.meta(), map[string]interface{}{
+		ConfigTypeName: RunningConfig,
+		RulestackName:  "rs",
+	})
+	if ds["names.#"] != "1" || ds["prefix_lists.0.name"] != "pl1" {
+		t.Errorf("running: got names %q, %q", ds["names.#"], ds["prefix_lists.0.name"])
+	}
+}
+
 // Resource.
 func TestAccResourcePrefixList(t *testing.T) {
 	name := fmt.Sprintf("tf%s", acctest.RandString(8))
@@ -85,6 +129,57 @@ func TestAccResourcePrefixList(t *testing.T) {
 	})
 }
 
+func TestResourcePrefixListOffline(t *testing.T) {
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	l := newTestLifecycle(t, resourcePrefixList(), m.meta())
+
+	raw := map[string]interface{}{
+		RulestackName: "rs",
+		"name":        "test",
+		"description": "first",
+		"prefix_list": []interface{}{"192.168.0.0", "10.1.0.0"},
+	}
+	l.apply(raw)
+	l.check(map[string]string{
+		"name":          "test",
+		"description":   "first",
+		"prefix_list.#": "2",
+	})
+	if m.Rulestack("rs").State != "Uncommitted" {
+		t.Errorf("Rulestack is not marked as uncommitted")
+	}
+	l.check(map[string]string{
+		"running_differs": "true",
+	})
+
+	m.commit(m.Rulestack("rs"))
+	if diags := l.refresh(); diags.HasError() {
+		t.Fatalf("Error refreshing: %s", diagsToString(diags))
+	}
+	l.check(map[string]string{
+		"running_differs": "false",
+		"running_diff.#":  "0",
+	})
+
+	raw["description"] = "second"
+	raw["prefix_list"] = []interface{}{"172.16.0.0"}
+	l.apply(raw)
+	l.check(map[string]string{
+		"description":     "second",
+		"prefix_list.#":   "1",
+		"running_differs": "true",
+		"running_diff.#":  "2",
+		"running_diff.0":  "description",
+		"running_diff.1":  "prefix_list",
+	})
+
+	l.destroy()
+	if len(m.Rulestack("rs").Objects["prefixlists"]["test"].Candidate) != 0 {
+		t.Fatalf("Prefix list was not deleted")
+	}
+}
+
 func testAccPrefixList(name string, x prefix.Info) string {
 	var buf strings.Builder
 
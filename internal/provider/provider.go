@@ -3,7 +3,9 @@ package provider
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 
@@ -37,27 +39,36 @@ func New(version string) func() *schema.Provider {
 			Schema: providerSchema(),
 
 			DataSourcesMap: map[string]*schema.Resource{
+				"cloudngfwaws_app_id_application":               dataSourceAppIdApplication(),
 				"cloudngfwaws_app_id_version":                   dataSourceAppIdVersion(),
 				"cloudngfwaws_app_id_versions":                  dataSourceAppIdVersions(),
 				"cloudngfwaws_certificate":                      dataSourceCertificate(),
+				"cloudngfwaws_certificates":                     dataSourceCertificates(),
 				"cloudngfwaws_country":                          dataSourceCountry(),
 				"cloudngfwaws_custom_url_category":              dataSourceCustomUrlCategory(),
+				"cloudngfwaws_custom_url_categories":            dataSourceCustomUrlCategories(),
 				"cloudngfwaws_fqdn_list":                        dataSourceFqdnList(),
+				"cloudngfwaws_fqdn_lists":                       dataSourceFqdnLists(),
 				"cloudngfwaws_ngfw":                             dataSourceNgfw(),
 				"cloudngfwaws_ngfws":                            dataSourceNgfws(),
 				"cloudngfwaws_ngfw_log_profile":                 dataSourceNgfwLogProfile(),
 				"cloudngfwaws_ngfw_tag":                         dataSourceNgfwTag(),
 				"cloudngfwaws_intelligent_feed":                 dataSourceIntelligentFeed(),
+				"cloudngfwaws_intelligent_feeds":                dataSourceIntelligentFeeds(),
 				"cloudngfwaws_predefined_url_categories":        dataSourcePredefinedUrlCategories(),
 				"cloudngfwaws_predefined_url_category_override": dataSourcePredefinedUrlCategoryOverride(),
 				"cloudngfwaws_prefix_list":                      dataSourcePrefixList(),
+				"cloudngfwaws_prefix_lists":                     dataSourcePrefixLists(),
 				"cloudngfwaws_rulestack":                        dataSourceRulestack(),
+				"cloudngfwaws_rulestack_diff":                   dataSourceRulestackDiff(),
 				"cloudngfwaws_rulestack_tag":                    dataSourceRulestackTag(),
 				"cloudngfwaws_security_rule":                    dataSourceSecurityRule(),
+				"cloudngfwaws_security_rules":                   dataSourceSecurityRules(),
 				"cloudngfwaws_validate_rulestack":               dataSourceValidateRulestack(),
 			},
 
 			ResourcesMap: map[string]*schema.Resource{
+				"cloudngfwaws_app_id_rollout":                   resourceAppIdRollout(),
 				"cloudngfwaws_certificate":                      resourceCertificate(),
 				"cloudngfwaws_commit_rulestack":                 resourceCommitRulestack(),
 				"cloudngfwaws_custom_url_category":              resourceCustomUrlCategory(),
@@ -71,9 +82,19 @@ func New(version string) func() *schema.Provider {
 				"cloudngfwaws_rulestack":                        resourceRulestack(),
 				"cloudngfwaws_rulestack_tag":                    resourceRulestackTag(),
 				"cloudngfwaws_security_rule":                    resourceSecurityRule(),
+				"cloudngfwaws_security_rules":                   resourceSecurityRules(),
 			},
 		}
 
+		// Everything can be managed in another region or account than the
+		// provider's.
+		for _, r := range p.DataSourcesMap {
+			addClientOverrideSchema(r, false)
+		}
+		for _, r := range p.ResourcesMap {
+			addClientOverrideSchema(r, true)
+		}
+
 		p.ConfigureContextFunc = configure(version, p)
 
 		return p
@@ -147,6 +168,104 @@ func providerSchema() map[string]*schema.Schema {
 				"lra-arn",
 			),
 		},
+		"external_id": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Description: addProviderParamDescription(
+				"(Used for the initial `sts assume role`) The external ID required by the roles being assumed.",
+				"CLOUDNGFWAWS_EXTERNAL_ID",
+				"external-id",
+			),
+			DefaultFunc: schema.EnvDefaultFunc("CLOUDNGFWAWS_EXTERNAL_ID", nil),
+		},
+		"role_session_name": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Description: addProviderParamDescription(
+				"(Used for the initial `sts assume role`) The session name to use when assuming the roles.",
+				"CLOUDNGFWAWS_ROLE_SESSION_NAME",
+				"role-session-name",
+			),
+			DefaultFunc:  schema.EnvDefaultFunc("CLOUDNGFWAWS_ROLE_SESSION_NAME", nil),
+			ValidateFunc: validation.StringLenBetween(2, 64),
+		},
+		"session_duration": {
+			Type:     schema.TypeInt,
+			Optional: true,
+			Description: addProviderParamDescription(
+				"(Used for the initial `sts assume role`) The duration, in seconds, of the role sessions.",
+				"CLOUDNGFWAWS_SESSION_DURATION",
+				"session-duration",
+			),
+			DefaultFunc:  schema.EnvDefaultFunc("CLOUDNGFWAWS_SESSION_DURATION", nil),
+			ValidateFunc: validation.IntBetween(900, 43200),
+		},
+		"session_tags": {
+			Type:     schema.TypeMap,
+			Optional: true,
+			Description: addProviderParamDescription(
+				"(Used for the initial `sts assume role`) Session tags to pass when assuming the roles.",
+				"",
+				"session-tags",
+			),
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"profile": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Description: addProviderParamDescription(
+				"(Used for the initial `sts assume role`) The AWS shared config profile to get credentials from.",
+				"CLOUDNGFWAWS_PROFILE",
+				"profile",
+			),
+			DefaultFunc: schema.EnvDefaultFunc("CLOUDNGFWAWS_PROFILE", nil),
+		},
+		"shared_config_files": {
+			Type:     schema.TypeList,
+			Optional: true,
+			Description: addProviderParamDescription(
+				"(Used for the initial `sts assume role`) AWS shared config files to use instead of the default.",
+				"",
+				"shared-config-files",
+			),
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"shared_credentials_files": {
+			Type:     schema.TypeList,
+			Optional: true,
+			Description: addProviderParamDescription(
+				"(Used for the initial `sts assume role`) AWS shared credentials files to use instead of the default.",
+				"",
+				"shared-credentials-files",
+			),
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"web_identity_token_file": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Description: addProviderParamDescription(
+				"(Used for the initial `sts assume role`) File containing an OIDC token, such as from a CI pipeline, to exchange for the credentials of `web_identity_role_arn`. The AWS SDK also honors `AWS_WEB_IDENTITY_TOKEN_FILE` and `AWS_ROLE_ARN`.",
+				"CLOUDNGFWAWS_WEB_IDENTITY_TOKEN_FILE",
+				"web-identity-token-file",
+			),
+			DefaultFunc: schema.EnvDefaultFunc("CLOUDNGFWAWS_WEB_IDENTITY_TOKEN_FILE", nil),
+		},
+		"web_identity_role_arn": {
+			Type:     schema.TypeString,
+			Optional: true,
+			Description: addProviderParamDescription(
+				"(Used for the initial `sts assume role`) The role to get credentials for with `web_identity_token_file`.",
+				"CLOUDNGFWAWS_WEB_IDENTITY_ROLE_ARN",
+				"web-identity-role-arn",
+			),
+			DefaultFunc: schema.EnvDefaultFunc("CLOUDNGFWAWS_WEB_IDENTITY_ROLE_ARN", nil),
+		},
 		"protocol": {
 			Type:     schema.TypeString,
 			Optional: true,
@@ -169,6 +288,46 @@ func providerSchema() map[string]*schema.Schema {
 				"timeout",
 			),
 		},
+		"max_retries": {
+			Type:     schema.TypeInt,
+			Optional: true,
+			Description: addProviderParamDescription(
+				fmt.Sprintf("Maximum number of times to retry an API call that was throttled or failed with a transient error; changes are only retried when throttled or when another operation is in progress (default: `%d`).", defaultMaxRetries),
+				"CLOUDNGFWAWS_MAX_RETRIES",
+				"",
+			),
+			DefaultFunc:  schema.EnvDefaultFunc("CLOUDNGFWAWS_MAX_RETRIES", defaultMaxRetries),
+			ValidateFunc: validation.IntAtLeast(0),
+		},
+		"retry_max_backoff": {
+			Type:     schema.TypeInt,
+			Optional: true,
+			Description: addProviderParamDescription(
+				fmt.Sprintf("Maximum number of seconds to wait between retries, unless the API asks for longer with `Retry-After` (default: `%d`).", defaultRetryMaxBackoff),
+				"CLOUDNGFWAWS_RETRY_MAX_BACKOFF",
+				"",
+			),
+			DefaultFunc:  schema.EnvDefaultFunc("CLOUDNGFWAWS_RETRY_MAX_BACKOFF", defaultRetryMaxBackoff),
+			ValidateFunc: validation.IntAtLeast(1),
+		},
+		"auto_commit": {
+			Type:     schema.TypeBool,
+			Optional: true,
+			Description: addProviderParamDescription(
+				"Plan a commit in the `cloudngfwaws_commit_rulestack` resource of every rulestack changed by the plan (default: `false`).",
+				"CLOUDNGFWAWS_AUTO_COMMIT",
+				"",
+			),
+			DefaultFunc: schema.EnvDefaultFunc("CLOUDNGFWAWS_AUTO_COMMIT", false),
+		},
+		"auto_commit_rulestacks": {
+			Type:        schema.TypeSet,
+			Optional:    true,
+			Description: "Rulestacks to auto commit as if `auto_commit` were enabled, when it is not.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
 		"headers": {
 			Type:     schema.TypeMap,
 			Optional: true,
@@ -235,6 +394,11 @@ func configure(version string, p *schema.Provider) func(context.Context, *schema
 			}
 		}
 
+		auth, err := loadAuthConfig(d)
+		if err != nil {
+			return nil, diag.FromErr(err)
+		}
+
 		if ll := d.Get("logging").([]interface{}); len(ll) > 0 {
 			for i := range ll {
 				s := ll[i].(string)
@@ -246,35 +410,85 @@ func configure(version string, p *schema.Provider) func(context.Context, *schema
 			}
 		}
 
-		con := &awsngfw.Client{
-			Host:                  d.Get("host").(string),
-			AccessKey:             d.Get("access_key").(string),
-			SecretKey:             d.Get("secret_key").(string),
-			Region:                d.Get("region").(string),
-			Arn:                   d.Get("arn").(string),
-			LfaArn:                d.Get("lfa_arn").(string),
-			LraArn:                d.Get("lra_arn").(string),
-			Protocol:              d.Get("protocol").(string),
-			Timeout:               d.Get("timeout").(int),
-			Headers:               hdrs,
-			SkipVerifyCertificate: d.Get("skip_verify_certificate").(bool),
-			Logging:               lc,
-			AuthFile:              d.Get("json_config_file").(string),
+		// newClient returns an authenticated client, with any region / role
+		// override applied to the provider config.
+		newClient := func(ctx context.Context, key clientKey) (*awsngfw.Client, error) {
+			con := &awsngfw.Client{
+				Host:                  d.Get("host").(string),
+				AccessKey:             d.Get("access_key").(string),
+				SecretKey:             d.Get("secret_key").(string),
+				Region:                d.Get("region").(string),
+				Arn:                   d.Get("arn").(string),
+				LfaArn:                d.Get("lfa_arn").(string),
+				LraArn:                d.Get("lra_arn").(string),
+				Protocol:              d.Get("protocol").(string),
+				Timeout:               d.Get("timeout").(int),
+				Headers:               hdrs,
+				SkipVerifyCertificate: d.Get("skip_verify_certificate").(bool),
+				Logging:               lc,
+				AuthFile:              d.Get("json_config_file").(string),
 
-			CheckEnvironment: true,
-			Agent:            p.UserAgent("terraform-provider-cloudngfwaws", version),
-		}
+				CheckEnvironment: true,
+				Agent:            p.UserAgent("terraform-provider-cloudngfwaws", version),
+			}
 
-		if err := con.Setup(); err != nil {
-			return nil, diag.FromErr(err)
-		}
+			if key.region != "" && key.region != con.Region {
+				host := con.Host
+				if host == "" {
+					host = os.Getenv("CLOUDNGFWAWS_HOST")
+				}
+				h, err := regionHost(host, key.region)
+				if err != nil {
+					return nil, err
+				}
+				con.Region, con.Host = key.region, h
+			}
+			if key.roleArn != "" {
+				con.Arn, con.LfaArn, con.LraArn = key.roleArn, key.roleArn, key.roleArn
+			}
+
+			if err := con.Setup(); err != nil {
+				return nil, err
+			}
+
+			jwts := newJwtRefresher(con)
+			if auth.enabled() {
+				refresh, err := auth.jwtRefresh(con)
+				if err != nil {
+					return nil, err
+				}
+				jwts.refresh = refresh
+			}
+
+			// Retry each logged API call, applying the timeout to every
+			// attempt, and refresh the JWTs before they expire.
+			con.HttpClient.Transport = newJwtRefreshTransport(
+				newRetryTransport(
+					logging.NewTransport("CloudNgfwAws", con.HttpClient.Transport),
+					d.Get("max_retries").(int),
+					time.Duration(d.Get("retry_max_backoff").(int))*time.Second,
+					con.HttpClient.Timeout,
+				),
+				jwts,
+			)
+			con.HttpClient.Timeout = 0
 
-		con.HttpClient.Transport = logging.NewTransport("CloudNgfwAws", con.HttpClient.Transport)
+			if err := jwts.start(ctx, con); err != nil {
+				return nil, err
+			}
+
+			return con, nil
+		}
 
-		if err := con.RefreshJwts(ctx); err != nil {
+		con, err := newClient(ctx, clientKey{})
+		if err != nil {
 			return nil, diag.FromErr(err)
 		}
 
-		return con, nil
+		return &providerMeta{
+			client:    con,
+			commits:   newAutoCommitter(d.Get("auto_commit").(bool), setToSlice(d.Get("auto_commit_rulestacks"))),
+			newClient: newClient,
+		}, nil
 	}
 }
@@ -0,0 +1,262 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/api"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Defaults for the max_retries and retry_max_backoff provider params.
+const (
+	defaultMaxRetries      = 5
+	defaultRetryMaxBackoff = 30
+)
+
+// The delay before the first retry, which doubles on each attempt.
+var retryMinBackoff = 1 * time.Second
+
+// retryTransport retries API calls that were throttled or that failed with a
+// transient error, backing off exponentially (with jitter) between attempts
+// and honoring any Retry-After header the API sends.
+//
+// The client's own timeout would cover every attempt, so it is applied here
+// to each attempt instead.
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	maxBackoff time.Duration
+	timeout    time.Duration
+}
+
+func newRetryTransport(next http.RoundTripper, maxRetries int, maxBackoff, timeout time.Duration) *retryTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &retryTransport{
+		next:       next,
+		maxRetries: maxRetries,
+		maxBackoff: maxBackoff,
+		timeout:    timeout,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	// Save the body so that it can be resent.
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	backoff := retryMinBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := t.roundTrip(ctx, req, body)
+
+		retry, after := t.shouldRetry(req, resp, err)
+		if !retry || attempt >= t.maxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		if wait > t.maxBackoff {
+			wait = t.maxBackoff
+		}
+		if after > wait {
+			wait = after
+		}
+
+		tflog.Info(
+			ctx, "retrying api call",
+			"method", req.Method,
+			"path", req.URL.Path,
+			"reason", retryReason(resp, err),
+			"attempt", attempt+1,
+			"wait", wait.String(),
+		)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > t.maxBackoff {
+			backoff = t.maxBackoff
+		}
+	}
+}
+
+// roundTrip performs a single attempt, reading the whole response body
+// before the per attempt timeout is released.
+func (t *retryTransport) roundTrip(ctx context.Context, req *http.Request, body []byte) (*http.Response, error) {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	r := req.Clone(ctx)
+	if body != nil {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	return resp, nil
+}
+
+// shouldRetry reports if the attempt should be retried, along with how long
+// the API asked us to wait, if it did.
+//
+// Only reads are retried after network errors and server errors, as a change
+// may have been made even though its call failed.  Changes are retried only
+// when the API says it did not get to them, as it was throttled or busy.
+func (t *retryTransport) shouldRetry(req *http.Request, resp *http.Response, err error) (bool, time.Duration) {
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+
+	if err != nil {
+		var ne net.Error
+		if idempotent && errors.As(err, &ne) {
+			return true, 0
+		}
+		return false, 0
+	}
+
+	after := retryAfter(resp.Header.Get("Retry-After"))
+
+	if resp.StatusCode == http.StatusTooManyRequests || (idempotent && isRetryableCode(resp.StatusCode)) {
+		return true, after
+	}
+
+	if s := responseStatus(resp); s != nil && isRetryableStatus(*s, idempotent) {
+		return true, after
+	}
+
+	return false, 0
+}
+
+// isRetryableStatus reports if the API error is due to throttling or to
+// another operation still being in progress, or for idempotent calls, to a
+// transient server error.
+func isRetryableStatus(s api.Status, idempotent bool) bool {
+	if s.ErrorCode == http.StatusTooManyRequests || (idempotent && isRetryableCode(s.ErrorCode)) {
+		return true
+	}
+
+	reasons := []string{"throttl", "rate exceeded", "too many requests", "in progress"}
+	if idempotent {
+		reasons = append(reasons, "try again")
+	}
+	reason := strings.ToLower(s.Reason)
+	for _, x := range reasons {
+		if strings.Contains(reason, x) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isRetryableCode(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// responseStatus returns the API status from the response body, if any.
+func responseStatus(resp *http.Response) *api.Status {
+	if resp == nil || resp.Body == nil {
+		return nil
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+
+	var ans struct {
+		Status *api.Status `json:"ResponseStatus"`
+	}
+	if json.Unmarshal(data, &ans) != nil || ans.Status == nil || ans.Status.ErrorCode == 0 {
+		return nil
+	}
+
+	return ans.Status
+}
+
+// retryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+func retryReason(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+
+	if s := responseStatus(resp); s != nil && s.Reason != "" {
+		return fmt.Sprintf("%s: %s", resp.Status, s.Reason)
+	}
+
+	return resp.Status
+}
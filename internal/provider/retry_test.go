@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/api"
+)
+
+func TestRetryTransport(t *testing.T) {
+	testFastRetries(t)
+
+	cases := []struct {
+		name     string
+		method   string
+		failures int
+		code     int
+		body     string
+		retries  int
+		calls    int32
+		ok       bool
+	}{
+		{"throttled", http.MethodPost, 2, http.StatusTooManyRequests, "", 5, 3, true},
+		{"unavailable", http.MethodGet, 1, http.StatusServiceUnavailable, "", 5, 2, true},
+		{"unavailable change", http.MethodPost, 1, http.StatusServiceUnavailable, "", 5, 1, false},
+		{"gateway timeout change", http.MethodDelete, 1, http.StatusGatewayTimeout, "", 5, 1, false},
+		{"update in progress", http.MethodPut, 2, http.StatusBadRequest, `{"ResponseStatus": {"ErrorCode": 400, "Reason": "Update in progress"}}`, 5, 3, true},
+		{"server error change", http.MethodPost, 1, http.StatusBadRequest, `{"ResponseStatus": {"ErrorCode": 500, "Reason": "Internal error"}}`, 5, 1, false},
+		{"bad request", http.MethodPost, 1, http.StatusBadRequest, `{"ResponseStatus": {"ErrorCode": 400, "Reason": "Invalid name"}}`, 5, 1, false},
+		{"out of retries", http.MethodPost, 5, http.StatusTooManyRequests, "", 2, 3, false},
+		{"retries disabled", http.MethodPost, 1, http.StatusTooManyRequests, "", 0, 1, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if body, _ := ioutil.ReadAll(r.Body); string(body) != "payload" {
+					t.Errorf("Attempt %d got body %q", atomic.LoadInt32(&calls)+1, body)
+				}
+				if int(atomic.AddInt32(&calls, 1)) <= tc.failures {
+					w.WriteHeader(tc.code)
+					fmt.Fprint(w, tc.body)
+					return
+				}
+				fmt.Fprint(w, `{"ResponseStatus": {"ErrorCode": 0}}`)
+			}))
+			defer srv.Close()
+
+			client := &http.Client{
+				Transport: newRetryTransport(nil, tc.retries, 10*time.Millisecond, time.Second),
+			}
+
+			req, _ := http.NewRequest(tc.method, srv.URL, strings.NewReader("payload"))
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Error: %s", err)
+			}
+			resp.Body.Close()
+
+			if calls != tc.calls {
+				t.Errorf("Got %d calls, expected %d", calls, tc.calls)
+			}
+			if ok := resp.StatusCode == http.StatusOK; ok != tc.ok {
+				t.Errorf("Got status %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	testFastRetries(t)
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{
+		Transport: newRetryTransport(nil, 3, 10*time.Millisecond, time.Second),
+	}
+
+	start := time.Now()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	resp.Body.Close()
+
+	if d := time.Since(start); d < time.Second {
+		t.Errorf("Retried after %s, ignoring Retry-After", d)
+	}
+	if calls != 2 {
+		t.Errorf("Got %d calls, expected 2", calls)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d := retryAfter("3"); d != 3*time.Second {
+		t.Errorf("Seconds: got %s", d)
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d := retryAfter(date); d < 50*time.Second || d > time.Minute {
+		t.Errorf("Date: got %s", d)
+	}
+
+	for _, v := range []string{"", "-1", "soon"} {
+		if d := retryAfter(v); d != 0 {
+			t.Errorf("%q: got %s", v, d)
+		}
+	}
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	cases := []struct {
+		status     api.Status
+		idempotent bool
+		ok         bool
+	}{
+		{api.Status{ErrorCode: 429}, false, true},
+		{api.Status{ErrorCode: 503}, true, true},
+		{api.Status{ErrorCode: 503}, false, false},
+		{api.Status{ErrorCode: 400, Reason: "Rate exceeded"}, false, true},
+		{api.Status{ErrorCode: 400, Reason: "Another commit is in progress"}, false, true},
+		{api.Status{ErrorCode: 400, Reason: "Please try again"}, true, true},
+		{api.Status{ErrorCode: 400, Reason: "Please try again"}, false, false},
+		{api.Status{ErrorCode: 400, Reason: "Invalid name"}, true, false},
+		{api.Status{ErrorCode: 404, Reason: "Not found"}, true, false},
+	}
+
+	for _, tc := range cases {
+		if ok := isRetryableStatus(tc.status, tc.idempotent); ok != tc.ok {
+			t.Errorf("%d %q (idempotent %t): got %t", tc.status.ErrorCode, tc.status.Reason, tc.idempotent, ok)
+		}
+	}
+}
+
+// testFastRetries shortens the retry backoff for the test.
+func testFastRetries(t *testing.T) {
+	backoff := retryMinBackoff
+	retryMinBackoff = time.Millisecond
+
+	t.Cleanup(func() {
+		retryMinBackoff = backoff
+	})
+}
+
+func TestRetryTransportChangeNotResentOffline(t *testing.T) {
+	testFastRetries(t)
+
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	meta := m.meta().(*providerMeta)
+	meta.client.HttpClient.Transport = newRetryTransport(meta.client.HttpClient.Transport, 5, 10*time.Millisecond, 10*time.Second)
+	l := newTestLifecycle(t, resourcePrefixList(), meta)
+
+	// The create is made, but the gateway times out before responding.
+	m.FailNextWrite = http.StatusBadGateway
+	diags := l.applyDiags(map[string]interface{}{
+		RulestackName: "rs",
+		"name":        "test",
+		"prefix_list": []interface{}{"10.1.0.0/16"},
+	})
+	if !diags.HasError() {
+		t.Fatalf("Create did not fail")
+	}
+
+	posts := 0
+	for _, req := range m.Requests {
+		if strings.HasPrefix(req, http.MethodPost+" ") && strings.Contains(req, "prefixlists") {
+			posts++
+		}
+	}
+	if posts != 1 {
+		t.Errorf("Create was sent %d times: %v", posts, m.Requests)
+	}
+}
@@ -23,7 +23,11 @@ func dataSourceRulestack() *schema.Resource {
 }
 
 func readRulestackDataSource(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := stack.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := stack.NewClient(con)
 
 	style := d.Get(ConfigTypeName).(string)
 	d.Set(ConfigTypeName, style)
@@ -81,16 +85,22 @@ func resourceRulestack() *schema.Resource {
 		UpdateContext: updateRulestack,
 		DeleteContext: deleteRulestack,
 
+		CustomizeDiff: customizeDiffAutoCommit("name"),
+
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
 
-		Schema: rulestackSchema(true, []string{ConfigTypeName}),
+		Schema: runningDiffSchema(rulestackSchema(true, []string{ConfigTypeName})),
 	}
 }
 
 func createRulestack(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := stack.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := stack.NewClient(con)
 	o := loadRulestack(d)
 	tflog.Info(
 		ctx, "create rulestack",
@@ -107,11 +117,16 @@ func createRulestack(ctx context.Context, d *schema.ResourceData, meta interface
 }
 
 func readRulestack(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := stack.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := stack.NewClient(con)
 	name := d.Id()
 	req := stack.ReadInput{
 		Name:      name,
 		Candidate: true,
+		Running:   true,
 	}
 	tflog.Info(
 		ctx, "read rulestack",
@@ -125,15 +140,28 @@ func readRulestack(ctx context.Context, d *schema.ResourceData, meta interface{}
 			return nil
 		}
 		return diag.FromErr(err)
+	} else if res.Response.Candidate == nil {
+		d.SetId("")
+		return nil
 	}
 
 	saveRulestack(d, res.Response.Name, res.Response.State, *res.Response.Candidate)
 
+	var running map[string]interface{}
+	if res.Response.Running != nil {
+		running = dumpRulestack(*res.Response.Running)
+	}
+	saveRunningDiff(d, dumpRulestack(*res.Response.Candidate), running)
+
 	return nil
 }
 
 func updateRulestack(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := stack.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := stack.NewClient(con)
 	o := loadRulestack(d)
 	tflog.Info(
 		ctx, "update rulestack",
@@ -149,7 +177,11 @@ func updateRulestack(ctx context.Context, d *schema.ResourceData, meta interface
 }
 
 func deleteRulestack(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := stack.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := stack.NewClient(con)
 	name := d.Id()
 	tflog.Info(
 		ctx, "delete rulestack",
@@ -164,6 +196,31 @@ func deleteRulestack(ctx context.Context, d *schema.ResourceData, meta interface
 	return nil
 }
 
+// rulestackMinAppIdVersion returns the minimum App-ID version of the
+// rulestack's running config, or of its candidate config if it was never
+// committed.  A rulestack that does not exist has no minimum.
+func rulestackMinAppIdVersion(ctx context.Context, con *awsngfw.Client, name string) (string, error) {
+	res, err := stack.NewClient(con).Read(ctx, stack.ReadInput{
+		Name:      name,
+		Candidate: true,
+		Running:   true,
+	})
+	if err != nil {
+		if isObjectNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if res.Response.Running != nil {
+		return res.Response.Running.MinimumAppIdVersion, nil
+	} else if res.Response.Candidate != nil {
+		return res.Response.Candidate.MinimumAppIdVersion, nil
+	}
+
+	return "", nil
+}
+
 // Schema handling.
 func rulestackSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 	ans := map[string]*schema.Schema{
@@ -295,6 +352,14 @@ func loadRulestack(d *schema.ResourceData) stack.Info {
 }
 
 func saveRulestack(d *schema.ResourceData, name, state string, o stack.Details) {
+	d.Set("name", name)
+	for key, value := range dumpRulestack(o) {
+		d.Set(key, value)
+	}
+	d.Set("state", state)
+}
+
+func dumpRulestack(o stack.Details) map[string]interface{} {
 	pc := map[string]interface{}{
 		"anti_spyware":                 o.Profile.AntiSpyware,
 		"anti_virus":                   o.Profile.AntiVirus,
@@ -305,13 +370,13 @@ func saveRulestack(d *schema.ResourceData, name, state string, o stack.Details)
 		"outbound_untrust_certificate": o.Profile.OutboundUntrustCertificate,
 	}
 
-	d.Set("name", name)
-	d.Set("description", o.Description)
-	d.Set("scope", o.Scope)
-	d.Set("account_id", o.AccountId)
-	d.Set("account_group", o.AccountGroup)
-	d.Set("minimum_app_id_version", o.MinimumAppIdVersion)
-	d.Set("profile_config", []interface{}{pc})
-	d.Set(TagsName, dumpTags(o.Tags))
-	d.Set("state", state)
+	return map[string]interface{}{
+		"description":            o.Description,
+		"scope":                  o.Scope,
+		"account_id":             o.AccountId,
+		"account_group":          o.AccountGroup,
+		"minimum_app_id_version": o.MinimumAppIdVersion,
+		"profile_config":         []interface{}{pc},
+		TagsName:                 dumpTags(o.Tags),
+	}
 }
@@ -0,0 +1,446 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/object/certificate"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/object/feed"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/object/fqdn"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/object/prefix"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/object/url"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/rule/security"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/rule/stack"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Valid values for the change of a rulestack diff entry.
+const (
+	changeAdded    = "added"
+	changeDeleted  = "deleted"
+	changeModified = "modified"
+)
+
+// Data source.
+func dataSourceRulestackDiff() *schema.Resource {
+	return &schema.Resource{
+		Description: "Data source for comparing the candidate config of a rulestack and everything in it to the running config.",
+
+		ReadContext: readRulestackDiff,
+
+		Schema: map[string]*schema.Schema{
+			RulestackName: rsSchema(),
+			"differs": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Set if the candidate config differs from the running config.",
+			},
+			"changes": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The uncommitted changes.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"kind": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "What changed, such as `rulestack`, `security_rule` or `prefix_list`.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The name.",
+						},
+						RuleListName: {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The rulebase, for security rules.",
+						},
+						"priority": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The priority, for security rules.",
+						},
+						"change": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: addStringInSliceValidation("The change.", []string{changeAdded, changeDeleted, changeModified}),
+						},
+						"fields": {
+							Type:        schema.TypeList,
+							Computed:    true,
+							Description: "The fields that differ.",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func readRulestackDiff(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	name := d.Get(RulestackName).(string)
+
+	tflog.Info(
+		ctx, "read rulestack diff",
+		"ds", true,
+		RulestackName, name,
+	)
+
+	changes, err := rulestackChanges(ctx, con, name)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(name)
+	d.Set("differs", len(changes) > 0)
+	d.Set("changes", changes)
+
+	return nil
+}
+
+// rulestackChanges compares the candidate config of the rulestack and
+// everything in it to the running config, returning the differences.
+func rulestackChanges(ctx context.Context, con *awsngfw.Client, name string) ([]interface{}, error) {
+	var changes []interface{}
+	add := func(kind, name, rlist string, priority int, candidate, running map[string]interface{}) {
+		fields := diffFields(candidate, running)
+		if len(fields) == 0 {
+			return
+		}
+
+		change := changeModified
+		if candidate == nil {
+			change = changeDeleted
+		} else if running == nil {
+			change = changeAdded
+		}
+
+		changes = append(changes, map[string]interface{}{
+			"kind":       kind,
+			"name":       name,
+			RuleListName: rlist,
+			"priority":   priority,
+			"change":     change,
+			"fields":     fields,
+		})
+	}
+
+	// The rulestack itself.
+	res, err := stack.NewClient(con).Read(ctx, stack.ReadInput{
+		Name:      name,
+		Candidate: true,
+		Running:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var candidate, running map[string]interface{}
+	if res.Response.Candidate != nil {
+		candidate = dumpRulestack(*res.Response.Candidate)
+	}
+	if res.Response.Running != nil {
+		running = dumpRulestack(*res.Response.Running)
+	}
+	add("rulestack", name, "", 0, candidate, running)
+
+	// Rulestack objects.
+	for _, kind := range rulestackObjectKinds {
+		names := make(map[string]bool)
+		for _, style := range []string{CandidateConfig, RunningConfig} {
+			list, err := kind.list(ctx, con, name, style)
+			if err != nil {
+				return nil, fmt.Errorf("Error listing %s objects: %s", kind.name, err)
+			}
+			for _, x := range list {
+				names[x] = true
+			}
+		}
+
+		for _, x := range sortedKeys(names) {
+			candidate, running, err := kind.read(ctx, con, name, x)
+			if err != nil {
+				return nil, fmt.Errorf("Error reading %s %q: %s", kind.name, x, err)
+			}
+			add(kind.name, x, "", 0, candidate, running)
+		}
+	}
+
+	// Security rules, compared by priority.
+	rlists := []string{"LocalRule"}
+	if res.Response.Candidate != nil && res.Response.Candidate.Scope == "Global" {
+		rlists = []string{"PreRule", "PostRule"}
+	}
+
+	svc := security.NewClient(con)
+	for _, rlist := range rlists {
+		configs := make(map[int][2]map[string]interface{})
+		for i, candidate := range []bool{true, false} {
+			list, err := readSecurityRuleList(ctx, svc, name, rlist, candidate)
+			if err != nil {
+				return nil, fmt.Errorf("Error reading %s security rules: %s", rlist, err)
+			}
+			for _, x := range list {
+				pair := configs[x.Priority]
+				pair[i] = dumpSecurityRuleEntry(x.Entry)
+				configs[x.Priority] = pair
+			}
+		}
+
+		priorities := make([]int, 0, len(configs))
+		for priority := range configs {
+			priorities = append(priorities, priority)
+		}
+		sort.Ints(priorities)
+
+		for _, priority := range priorities {
+			pair := configs[priority]
+			rname := pair[0]["name"]
+			if pair[0] == nil {
+				rname = pair[1]["name"]
+			}
+			add("security_rule", rname.(string), rlist, priority, pair[0], pair[1])
+		}
+	}
+
+	return changes, nil
+}
+
+// rulestackObjectKind lists and reads one kind of rulestack object.
+type rulestackObjectKind struct {
+	name string
+	list func(context.Context, *awsngfw.Client, string, string) ([]string, error)
+	read func(context.Context, *awsngfw.Client, string, string) (map[string]interface{}, map[string]interface{}, error)
+}
+
+var rulestackObjectKinds = []rulestackObjectKind{
+	{
+		name: "certificate",
+		list: func(ctx context.Context, con *awsngfw.Client, stack, style string) ([]string, error) {
+			return listCertificateNames(ctx, certificate.NewClient(con), stack, style)
+		},
+		read: func(ctx context.Context, con *awsngfw.Client, stack, name string) (map[string]interface{}, map[string]interface{}, error) {
+			c, r, err := readCertificateConfigs(ctx, certificate.NewClient(con), stack, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			var cd, rd map[string]interface{}
+			if c != nil {
+				cd = dumpCertificate(name, *c)
+			}
+			if r != nil {
+				rd = dumpCertificate(name, *r)
+			}
+			return cd, rd, nil
+		},
+	},
+	{
+		name: "custom_url_category",
+		list: func(ctx context.Context, con *awsngfw.Client, stack, style string) ([]string, error) {
+			return listCustomUrlCategoryNames(ctx, url.NewClient(con), stack, style)
+		},
+		read: func(ctx context.Context, con *awsngfw.Client, stack, name string) (map[string]interface{}, map[string]interface{}, error) {
+			c, r, err := readCustomUrlCategoryConfigs(ctx, url.NewClient(con), stack, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			var cd, rd map[string]interface{}
+			if c != nil {
+				cd = dumpCustomUrlCategory(name, *c)
+			}
+			if r != nil {
+				rd = dumpCustomUrlCategory(name, *r)
+			}
+			return cd, rd, nil
+		},
+	},
+	{
+		name: "fqdn_list",
+		list: func(ctx context.Context, con *awsngfw.Client, stack, style string) ([]string, error) {
+			return listFqdnListNames(ctx, fqdn.NewClient(con), stack, style)
+		},
+		read: func(ctx context.Context, con *awsngfw.Client, stack, name string) (map[string]interface{}, map[string]interface{}, error) {
+			c, r, err := readFqdnListConfigs(ctx, fqdn.NewClient(con), stack, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			var cd, rd map[string]interface{}
+			if c != nil {
+				cd = dumpFqdnList(name, *c)
+			}
+			if r != nil {
+				rd = dumpFqdnList(name, *r)
+			}
+			return cd, rd, nil
+		},
+	},
+	{
+		name: "intelligent_feed",
+		list: func(ctx context.Context, con *awsngfw.Client, stack, style string) ([]string, error) {
+			return listIntelligentFeedNames(ctx, feed.NewClient(con), stack, style)
+		},
+		read: func(ctx context.Context, con *awsngfw.Client, stack, name string) (map[string]interface{}, map[string]interface{}, error) {
+			c, r, err := readIntelligentFeedConfigs(ctx, feed.NewClient(con), stack, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			var cd, rd map[string]interface{}
+			if c != nil {
+				cd = dumpIntelligentFeed(name, *c)
+			}
+			if r != nil {
+				rd = dumpIntelligentFeed(name, *r)
+			}
+			return cd, rd, nil
+		},
+	},
+	{
+		name: "prefix_list",
+		list: func(ctx context.Context, con *awsngfw.Client, stack, style string) ([]string, error) {
+			return listPrefixListNames(ctx, prefix.NewClient(con), stack, style)
+		},
+		read: func(ctx context.Context, con *awsngfw.Client, stack, name string) (map[string]interface{}, map[string]interface{}, error) {
+			c, r, err := readPrefixListConfigs(ctx, prefix.NewClient(con), stack, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			var cd, rd map[string]interface{}
+			if c != nil {
+				cd = dumpPrefixList(name, *c)
+			}
+			if r != nil {
+				rd = dumpPrefixList(name, *r)
+			}
+			return cd, rd, nil
+		},
+	},
+}
+
+// Candidate / running comparison.
+
+// Fields that are not part of the committed config.
+var runningDiffIgnored = map[string]bool{
+	"update_token": true,
+	TagsName:       true,
+}
+
+// runningDiffSchema adds the running config comparison params to a schema.
+func runningDiffSchema(sm map[string]*schema.Schema) map[string]*schema.Schema {
+	sm["running_differs"] = &schema.Schema{
+		Type:        schema.TypeBool,
+		Computed:    true,
+		Description: "Set if the candidate config differs from the running config, meaning there are uncommitted changes.",
+	}
+	sm["running_diff"] = &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: "The fields whose candidate config differs from the running config.",
+		Elem: &schema.Schema{
+			Type: schema.TypeString,
+		},
+	}
+
+	return sm
+}
+
+func saveRunningDiff(d *schema.ResourceData, candidate, running map[string]interface{}) {
+	fields := diffFields(candidate, running)
+
+	d.Set("running_differs", len(fields) > 0)
+	d.Set("running_diff", fields)
+}
+
+// diffFields returns the sorted names of the fields that differ between two
+// config dumps.  If either config is nil, every field differs.
+func diffFields(candidate, running map[string]interface{}) []string {
+	keys := make(map[string]bool)
+	for key := range candidate {
+		keys[key] = true
+	}
+	for key := range running {
+		keys[key] = true
+	}
+
+	var ans []string
+	for _, key := range sortedKeys(keys) {
+		if runningDiffIgnored[key] {
+			continue
+		}
+
+		if candidate == nil || running == nil || !reflect.DeepEqual(normalizeDumpValue(candidate[key]), normalizeDumpValue(running[key])) {
+			ans = append(ans, key)
+		}
+	}
+
+	return ans
+}
+
+// normalizeDumpValue makes sets comparable and treats empty values as unset.
+func normalizeDumpValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case *schema.Set:
+		ans, _ := normalizeDumpValue(x.List()).([]interface{})
+		if ans == nil {
+			return nil
+		}
+		sort.Slice(ans, func(i, j int) bool {
+			return fmt.Sprintf("%v", ans[i]) < fmt.Sprintf("%v", ans[j])
+		})
+		return ans
+	case []interface{}:
+		if len(x) == 0 {
+			return nil
+		}
+		ans := make([]interface{}, 0, len(x))
+		for _, item := range x {
+			ans = append(ans, normalizeDumpValue(item))
+		}
+		return ans
+	case []string:
+		if len(x) == 0 {
+			return nil
+		}
+		return x
+	case map[string]interface{}:
+		if len(x) == 0 {
+			return nil
+		}
+		ans := make(map[string]interface{}, len(x))
+		for key, item := range x {
+			if item = normalizeDumpValue(item); item != nil {
+				ans[key] = item
+			}
+		}
+		return ans
+	case string:
+		if x == "" {
+			return nil
+		}
+	}
+
+	return v
+}
+
+func sortedKeys(m map[string]bool) []string {
+	ans := make([]string, 0, len(m))
+	for key := range m {
+		ans = append(ans, key)
+	}
+	sort.Strings(ans)
+
+	return ans
+}
@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Data source.
+func TestDataSourceRulestackDiffOffline(t *testing.T) {
+	m := newMockApi(t)
+	rs := m.addRulestack("rs")
+
+	pl := newTestLifecycle(t, resourcePrefixList(), m.meta())
+	plRaw := map[string]interface{}{
+		RulestackName: "rs",
+		"name":        "pl",
+		"prefix_list": []interface{}{"10.0.0.0/8"},
+	}
+	pl.apply(plRaw)
+
+	rule := newTestLifecycle(t, resourceSecurityRule(), m.meta())
+	rule.apply(testSecurityRuleRaw("rs", "allow", 1))
+
+	m.commit(rs)
+	ds := testReadDataSource(t, dataSourceRulestackDiff(), m.meta(), map[string]interface{}{
+		RulestackName: "rs",
+	})
+	if ds["differs"] != "false" || ds["changes.#"] != "0" {
+		t.Fatalf("After commit: differs %q with %q changes", ds["differs"], ds["changes.#"])
+	}
+
+	plRaw["prefix_list"] = []interface{}{"10.0.0.0/8", "172.16.0.0/12"}
+	pl.apply(plRaw)
+	rule.destroy()
+	fl := newTestLifecycle(t, resourceFqdnList(), m.meta())
+	fl.apply(map[string]interface{}{
+		RulestackName: "rs",
+		"name":        "fl",
+		"fqdn_list":   []interface{}{"example.com"},
+	})
+
+	ds = testReadDataSource(t, dataSourceRulestackDiff(), m.meta(), map[string]interface{}{
+		RulestackName: "rs",
+	})
+	want := map[string]string{
+		"differs":             "true",
+		"changes.#":           "3",
+		"changes.0.kind":      "fqdn_list",
+		"changes.0.name":      "fl",
+		"changes.0.change":    changeAdded,
+		"changes.1.kind":      "prefix_list",
+		"changes.1.name":      "pl",
+		"changes.1.change":    changeModified,
+		"changes.1.fields.#":  "1",
+		"changes.1.fields.0":  "prefix_list",
+		"changes.2.kind":      "security_rule",
+		"changes.2.name":      "allow",
+		"changes.2.rule_list": "LocalRule",
+		"changes.2.priority":  "1",
+		"changes.2.change":    changeDeleted,
+	}
+	for key, value := range want {
+		if ds[key] != value {
+			t.Errorf("%s: got %q, expected %q", key, ds[key], value)
+		}
+	}
+}
+
+// Candidate / running comparison.
+func TestDiffFields(t *testing.T) {
+	set := func(v ...interface{}) *schema.Set {
+		return schema.NewSet(schema.HashString, v)
+	}
+
+	running := map[string]interface{}{
+		"description":  "desc",
+		"audit":        "",
+		"list":         set("a", "b"),
+		"nested":       []interface{}{map[string]interface{}{"x": set(), "y": "1"}},
+		"update_token": "1",
+		TagsName:       map[string]interface{}{"k": "v"},
+	}
+
+	cases := []struct {
+		name      string
+		candidate map[string]interface{}
+		running   map[string]interface{}
+		want      []string
+	}{
+		{"same", map[string]interface{}{
+			"description":  "desc",
+			"list":         set("b", "a"),
+			"nested":       []interface{}{map[string]interface{}{"y": "1"}},
+			"update_token": "2",
+		}, running, nil},
+		{"changed", map[string]interface{}{
+			"description": "other",
+			"audit":       "comment",
+			"list":        set("a"),
+			"nested":      []interface{}{map[string]interface{}{"y": "2"}},
+		}, running, []string{"audit", "description", "list", "nested"}},
+		{"not running", map[string]interface{}{
+			"description": "desc",
+			"list":        set("a"),
+		}, nil, []string{"description", "list"}},
+	}
+
+	for _, tc := range cases {
+		if got := diffFields(tc.candidate, tc.running); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, expected %v", tc.name, got, tc.want)
+		}
+	}
+}
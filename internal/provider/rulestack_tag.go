@@ -3,7 +3,6 @@ package provider
 import (
 	"context"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/tag"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/tag/rulestack"
 
@@ -24,7 +23,11 @@ func dataSourceRulestackTag() *schema.Resource {
 }
 
 func readRulestackTagDataSource(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := rulestack.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := rulestack.NewClient(con)
 
 	req := rulestack.ListInput{
 		Rulestack:  d.Get(RulestackName).(string),
@@ -39,7 +42,7 @@ func readRulestackTagDataSource(ctx context.Context, d *schema.ResourceData, met
 		RulestackName, req.Rulestack,
 	)
 
-	res, err := svc.List(ctx, req)
+	tags, err := listRulestackTags(ctx, svc, req)
 	if err != nil {
 		if isObjectNotFound(err) {
 			d.SetId("")
@@ -50,7 +53,7 @@ func readRulestackTagDataSource(ctx context.Context, d *schema.ResourceData, met
 
 	d.SetId(id)
 
-	saveRulestackTag(d, req.Rulestack, res.Response.Tags)
+	saveRulestackTag(d, req.Rulestack, tags)
 
 	return nil
 }
@@ -74,7 +77,11 @@ func resourceRulestackTag() *schema.Resource {
 }
 
 func createUpdateRulestackTag(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := rulestack.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := rulestack.NewClient(con)
 	o := loadRulestackTag(d)
 	tflog.Info(
 		ctx, "modify rulestack tags",
@@ -91,13 +98,17 @@ func createUpdateRulestackTag(ctx context.Context, d *schema.ResourceData, meta
 }
 
 func readRulestackTag(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := rulestack.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := rulestack.NewClient(con)
 
 	rs := d.Id()
 
 	req := rulestack.ListInput{
 		Rulestack:  rs,
-		MaxResults: 100,
+		MaxResults: 1000,
 	}
 
 	tflog.Info(
@@ -105,7 +116,7 @@ func readRulestackTag(ctx context.Context, d *schema.ResourceData, meta interfac
 		RulestackName, req.Rulestack,
 	)
 
-	res, err := svc.List(ctx, req)
+	tags, err := listRulestackTags(ctx, svc, req)
 	if err != nil {
 		if isObjectNotFound(err) {
 			d.SetId("")
@@ -114,13 +125,17 @@ func readRulestackTag(ctx context.Context, d *schema.ResourceData, meta interfac
 		return diag.FromErr(err)
 	}
 
-	saveRulestackTag(d, req.Rulestack, res.Response.Tags)
+	saveRulestackTag(d, req.Rulestack, tags)
 
 	return nil
 }
 
 func deleteRulestackTag(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := rulestack.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := rulestack.NewClient(con)
 
 	rs := d.Id()
 
@@ -137,6 +152,24 @@ func deleteRulestackTag(ctx context.Context, d *schema.ResourceData, meta interf
 	return nil
 }
 
+// listRulestackTags returns every tag, following pagination.
+func listRulestackTags(ctx context.Context, svc *rulestack.Client, req rulestack.ListInput) ([]tag.Details, error) {
+	var ans []tag.Details
+
+	_, err := readPages("", true, 0, func(token string) (int, string, error) {
+		req.NextToken = token
+		res, err := svc.List(ctx, req)
+		if err != nil {
+			return 0, "", err
+		}
+
+		ans = append(ans, res.Response.Tags...)
+		return len(res.Response.Tags), res.Response.NextToken, nil
+	})
+
+	return ans, err
+}
+
 // Schema handling.
 func rulestackTagSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
 	ans := map[string]*schema.Schema{
@@ -105,6 +105,59 @@ func TestAccResourceRulestack(t *testing.T) {
 	})
 }
 
+func TestResourceRulestackOffline(t *testing.T) {
+	m := newMockApi(t)
+	l := newTestLifecycle(t, resourceRulestack(), m.meta())
+
+	l.apply(testRulestackRaw("test", "This is my first description"))
+	l.check(map[string]string{
+		"name":                        "test",
+		"description":                 "This is my first description",
+		"profile_config.0.anti_virus": "BestPractice",
+		"state":                       "Uncommitted",
+	})
+
+	l.apply(testRulestackRaw("test", "Second description"))
+	l.check(map[string]string{
+		"description": "Second description",
+	})
+
+	// Changes made outside of Terraform are detected.
+	m.Rulestack("test").Candidate["Description"] = "drift"
+	if diags := l.refresh(); diags.HasError() {
+		t.Fatalf("Error refreshing: %s", diagsToString(diags))
+	}
+	l.check(map[string]string{
+		"description": "drift",
+	})
+
+	ds := testReadDataSource(t, dataSourceRulestack(), m.meta(), map[string]interface{}{
+		"name": "test",
+	})
+	if ds["description"] != "drift" {
+		t.Errorf("Data source description is %q, not %q", ds["description"], "drift")
+	}
+
+	l.destroy()
+	if m.Rulestack("test") != nil {
+		t.Fatalf("Rulestack was not deleted")
+	}
+}
+
+func testRulestackRaw(name, desc string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"description": desc,
+		"scope":       "Local",
+		"account_id":  mockAccountId,
+		"profile_config": []interface{}{
+			map[string]interface{}{
+				"anti_spyware": "BestPractice",
+			},
+		},
+	}
+}
+
 func testAccRulestackConfig(id string, x *stack.Details) string {
 	var buf strings.Builder
 
@@ -105,6 +105,52 @@ func sliceToSet(s []string) *schema.Set {
 	return schema.NewSet(schema.HashString, items)
 }
 
+func allPagesSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Description: "Retrieve every page of results instead of just one, using `max_results` as the page size.",
+		Default:     false,
+	}
+}
+
+func maxTotalResultsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:         schema.TypeInt,
+		Optional:     true,
+		Description:  "If `all_pages` is enabled, return an error instead of retrieving more than this many results.",
+		Default:      10000,
+		ValidateFunc: validation.IntAtLeast(1),
+	}
+}
+
+// objectListSchema is the schema for a data source listing every object of a
+// type in a rulestack, where elem is the schema of each object.
+func objectListSchema(key, desc string, elem map[string]*schema.Schema) map[string]*schema.Schema {
+	computed(elem, "", nil)
+
+	return map[string]*schema.Schema{
+		ConfigTypeName: configTypeSchema(),
+		RulestackName:  rsSchema(),
+		"names": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The object names.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		key: {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: desc,
+			Elem: &schema.Resource{
+				Schema: elem,
+			},
+		},
+	}
+}
+
 func tagsSchema(isOptional, forceNew bool) *schema.Schema {
 	return &schema.Schema{
 		Type:        schema.TypeMap,
@@ -5,12 +5,13 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/rule/security"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
@@ -27,7 +28,11 @@ func dataSourceSecurityRule() *schema.Resource {
 }
 
 func readSecurityRuleDataSource(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := security.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := security.NewClient(con)
 
 	style := d.Get(ConfigTypeName).(string)
 	d.Set(ConfigTypeName, style)
@@ -36,7 +41,7 @@ func readSecurityRuleDataSource(ctx context.Context, d *schema.ResourceData, met
 	rlist := d.Get(RuleListName).(string)
 	priority := d.Get("priority").(int)
 
-	id := configTypeId(style, buildSecurityRuleId(stack, rlist, priority))
+	id := configTypeId(style, buildSecurityRuleId(stack, rlist, strconv.Itoa(priority)))
 
 	req := security.ReadInput{
 		Rulestack: stack,
@@ -93,16 +98,115 @@ func resourceSecurityRule() *schema.Resource {
 		UpdateContext: updateSecurityRule,
 		DeleteContext: deleteSecurityRule,
 
+		CustomizeDiff: customdiff.All(
+			customizeDiffSecurityRule,
+			customizeDiffAutoCommit(RulestackName),
+		),
+
 		Importer: &schema.ResourceImporter{
 			State: schema.ImportStatePassthrough,
 		},
 
-		Schema: securityRuleSchema(true, []string{ConfigTypeName}),
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+		},
+
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    resourceSecurityRuleV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: upgradeSecurityRuleV0,
+			},
+		},
+
+		Schema: runningDiffSchema(securityRuleSchema(true, []string{ConfigTypeName})),
 	}
 }
 
+// resourceSecurityRuleV0 is the schema of version 0, frozen so that later
+// schema changes do not break upgrading old state.
+func resourceSecurityRuleV0() *schema.Resource {
+	set := func() *schema.Schema {
+		return &schema.Schema{
+			Type:     schema.TypeSet,
+			Optional: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		}
+	}
+	block := func(sets ...string) *schema.Schema {
+		ans := &schema.Schema{
+			Type:     schema.TypeList,
+			Required: true,
+			MaxItems: 1,
+			Elem:     &schema.Resource{Schema: map[string]*schema.Schema{}},
+		}
+		for _, key := range sets {
+			ans.Elem.(*schema.Resource).Schema[key] = set()
+		}
+		return ans
+	}
+
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			RulestackName:        {Type: schema.TypeString, Required: true},
+			RuleListName:         {Type: schema.TypeString, Optional: true},
+			"priority":           {Type: schema.TypeInt, Required: true},
+			"name":               {Type: schema.TypeString, Required: true},
+			"description":        {Type: schema.TypeString, Optional: true},
+			"enabled":            {Type: schema.TypeBool, Optional: true},
+			"source":             block("cidrs", "countries", "feeds", "prefix_lists"),
+			"negate_source":      {Type: schema.TypeBool, Optional: true},
+			"destination":        block("cidrs", "countries", "feeds", "prefix_lists", "fqdn_lists"),
+			"negate_destination": {Type: schema.TypeBool, Optional: true},
+			"applications": {
+				Type:     schema.TypeSet,
+				Required: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"category":             block("url_category_names", "feeds"),
+			"protocol":             {Type: schema.TypeString, Optional: true},
+			"audit_comment":        {Type: schema.TypeString, Optional: true},
+			"action":               {Type: schema.TypeString, Required: true},
+			"logging":              {Type: schema.TypeBool, Optional: true},
+			"decryption_rule_type": {Type: schema.TypeString, Optional: true},
+			TagsName: {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"update_token": {Type: schema.TypeString, Computed: true},
+		},
+	}
+}
+
+// Version 0 IDs used the priority instead of the name.
+func upgradeSecurityRuleV0(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	id, _ := rawState["id"].(string)
+	tok := strings.Split(id, IdSeparator)
+	if len(tok) != 3 {
+		return nil, fmt.Errorf("Expecting 3 tokens, got %d", len(tok))
+	}
+
+	name, _ := rawState["name"].(string)
+	rawState["id"] = buildSecurityRuleId(tok[0], tok[1], name)
+
+	tflog.Info(
+		ctx, "upgrade security rule id",
+		"from", id,
+		"to", rawState["id"],
+	)
+
+	return rawState, nil
+}
+
 func createSecurityRule(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := security.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := security.NewClient(con)
 	o := loadSecurityRule(d)
 	tflog.Info(
 		ctx, "create security rule",
@@ -112,51 +216,93 @@ func createSecurityRule(ctx context.Context, d *schema.ResourceData, meta interf
 		"name", o.Entry.Name,
 	)
 
+	// Another rule in this apply may still be moving out of this priority.
+	if err := waitForSecurityRulePriority(ctx, svc, o.Rulestack, o.RuleList, o.Priority); err != nil {
+		return diag.FromErr(err)
+	}
+
 	if err := svc.Create(ctx, o); err != nil {
 		return diag.FromErr(err)
 	}
 
-	d.SetId(buildSecurityRuleId(o.Rulestack, o.RuleList, o.Priority))
+	d.SetId(buildSecurityRuleId(o.Rulestack, o.RuleList, o.Entry.Name))
 
 	return readSecurityRule(ctx, d, meta)
 }
 
 func readSecurityRule(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := security.NewClient(meta.(*awsngfw.Client))
-	stack, rlist, priority, err := parseSecurityRuleId(d.Id())
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := security.NewClient(con)
+	stack, rlist, name, err := parseSecurityRuleId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
 	}
 
-	req := security.ReadInput{
-		Rulestack: stack,
-		RuleList:  rlist,
-		Priority:  priority,
-		Candidate: true,
+	// The priority is only known after the first read.
+	var info *security.Details
+	priority := d.Get("priority").(int)
+	if priority > 0 {
+		req := security.ReadInput{
+			Rulestack: stack,
+			RuleList:  rlist,
+			Priority:  priority,
+			Candidate: true,
+		}
+		tflog.Info(
+			ctx, "read security rule",
+			RulestackName, req.Rulestack,
+			RuleListName, req.RuleList,
+			"priority", req.Priority,
+		)
+
+		res, err := svc.Read(ctx, req)
+		if err != nil && !isObjectNotFound(err) {
+			return diag.FromErr(err)
+		} else if err == nil && res.Response.Candidate.Name == name {
+			info = res.Response.Candidate
+		}
 	}
-	tflog.Info(
-		ctx, "read security rule",
-		RulestackName, req.Rulestack,
-		RuleListName, req.RuleList,
-		"priority", req.Priority,
-	)
 
-	res, err := svc.Read(ctx, req)
-	if err != nil {
-		if isObjectNotFound(err) {
+	// The rule has moved (or this is an import), so find it by name.
+	if info == nil {
+		priority, info, err = findSecurityRule(ctx, svc, stack, rlist, name)
+		if err != nil {
+			return diag.FromErr(err)
+		} else if info == nil {
 			d.SetId("")
 			return nil
 		}
-		return diag.FromErr(err)
 	}
 
-	saveSecurityRule(d, stack, rlist, priority, *res.Response.Candidate)
+	saveSecurityRule(d, stack, rlist, priority, *info)
+
+	// Compare against the running rule at the same priority.
+	var running map[string]interface{}
+	res, err := svc.Read(ctx, security.ReadInput{
+		Rulestack: stack,
+		RuleList:  rlist,
+		Priority:  priority,
+		Running:   true,
+	})
+	if err != nil && !isObjectNotFound(err) {
+		return diag.FromErr(err)
+	} else if err == nil && res.Response.Running != nil && res.Response.Running.Name == name {
+		running = dumpSecurityRuleEntry(*res.Response.Running)
+	}
+	saveRunningDiff(d, dumpSecurityRuleEntry(*info), running)
 
 	return nil
 }
 
 func updateSecurityRule(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := security.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := security.NewClient(con)
 	o := loadSecurityRule(d)
 	tflog.Info(
 		ctx, "update security rule",
@@ -165,25 +311,38 @@ func updateSecurityRule(ctx context.Context, d *schema.ResourceData, meta interf
 		"priority", o.Priority,
 	)
 
-	if err := svc.Update(ctx, o); err != nil {
+	if d.HasChange("priority") {
+		prev, _ := d.GetChange("priority")
+		if err := moveSecurityRule(ctx, svc, o, prev.(int)); err != nil {
+			return diag.FromErr(err)
+		}
+	} else if err := svc.Update(ctx, o); err != nil {
 		return diag.FromErr(err)
 	}
 
+	d.SetId(buildSecurityRuleId(o.Rulestack, o.RuleList, o.Entry.Name))
+
 	return readSecurityRule(ctx, d, meta)
 }
 
 func deleteSecurityRule(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	svc := security.NewClient(meta.(*awsngfw.Client))
-	stack, rlist, priority, err := parseSecurityRuleId(d.Id())
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := security.NewClient(con)
+	stack, rlist, name, err := parseSecurityRuleId(d.Id())
 	if err != nil {
 		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
 	}
+	priority := d.Get("priority").(int)
 
 	tflog.Info(
 		ctx, "delete security rule",
 		RulestackName, stack,
 		RuleListName, rlist,
 		"priority", priority,
+		"name", name,
 	)
 
 	if err := svc.Delete(ctx, stack, rlist, priority); err != nil && !isObjectNotFound(err) {
@@ -194,11 +353,162 @@ func deleteSecurityRule(ctx context.Context, d *schema.ResourceData, meta interf
 	return nil
 }
 
+// customizeDiffSecurityRule checks changed applications against the App-ID
+// catalog.
+func customizeDiffSecurityRule(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.HasChange("applications") || !d.NewValueKnown("applications") || !d.NewValueKnown(RulestackName) {
+		return nil
+	}
+
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return err
+	}
+
+	return checkApplications(ctx, meta, con, d.Get(RulestackName).(string), setToSlice(d.Get("applications")))
+}
+
+// Priority handling.
+
+// moveSecurityRule deletes the rule from its previous priority before creating
+// it at the new one, so that rules swapping or shifting priorities free up
+// their slot for the next rule.  If the new priority does not free up in time,
+// the rule is restored at its previous priority as it was before the move.
+// Only the candidate config changes, so traffic is unaffected while the rule
+// is briefly absent.
+func moveSecurityRule(ctx context.Context, svc *security.Client, o security.Info, prev int) error {
+	tflog.Info(
+		ctx, "move security rule",
+		RulestackName, o.Rulestack,
+		RuleListName, o.RuleList,
+		"from", prev,
+		"to", o.Priority,
+	)
+
+	// Keep the rule as it is now, to restore it if the move fails.
+	var orig *security.Info
+	res, err := svc.Read(ctx, security.ReadInput{
+		Rulestack: o.Rulestack,
+		RuleList:  o.RuleList,
+		Priority:  prev,
+		Candidate: true,
+	})
+	if err != nil && !isObjectNotFound(err) {
+		return err
+	} else if err == nil && res.Response.Candidate != nil {
+		orig = &security.Info{
+			Rulestack: o.Rulestack,
+			RuleList:  o.RuleList,
+			Priority:  prev,
+			Entry:     *res.Response.Candidate,
+		}
+	}
+
+	if err = svc.Delete(ctx, o.Rulestack, o.RuleList, prev); err != nil && !isObjectNotFound(err) {
+		return err
+	}
+
+	err = waitForSecurityRulePriority(ctx, svc, o.Rulestack, o.RuleList, o.Priority)
+	if err == nil {
+		if err = svc.Create(ctx, o); err == nil {
+			return nil
+		}
+	}
+	if orig == nil {
+		return err
+	}
+
+	// Put the rule back where it was, even if ctx has expired.
+	if err2 := svc.Create(context.Background(), *orig); err2 != nil {
+		return fmt.Errorf("%s (restoring the rule at priority %d also failed: %s)", err, prev, err2)
+	}
+
+	return err
+}
+
+// waitForSecurityRulePriority waits until the given priority in the candidate
+// config is not in use by any rule, such as a rule that is being moved or
+// deleted in the same apply, until ctx is done.
+func waitForSecurityRulePriority(ctx context.Context, svc *security.Client, stack, rlist string, priority int) error {
+	desc := fmt.Sprintf("priority %d of %s %s to be free", priority, stack, rlist)
+
+	_, err := pollStatus(ctx, desc, func() (string, bool, error) {
+		req := security.ReadInput{
+			Rulestack: stack,
+			RuleList:  rlist,
+			Priority:  priority,
+			Candidate: true,
+		}
+
+		res, err := svc.Read(ctx, req)
+		if err != nil {
+			if isObjectNotFound(err) {
+				return "free", true, nil
+			}
+			return "", false, err
+		}
+
+		return fmt.Sprintf("in use by rule %q", res.Response.Candidate.Name), false, nil
+	})
+
+	return err
+}
+
+// findSecurityRule searches the candidate config for the rule with the given
+// name, returning a nil rule if it is not present.
+func findSecurityRule(ctx context.Context, svc *security.Client, stack, rlist, name string) (int, *security.Details, error) {
+	input := security.ListInput{
+		Rulestack:  stack,
+		RuleList:   rlist,
+		Candidate:  true,
+		MaxResults: 1000,
+	}
+
+	tflog.Info(
+		ctx, "find security rule",
+		RulestackName, stack,
+		RuleListName, rlist,
+		"name", name,
+	)
+
+	for {
+		ans, err := svc.List(ctx, input)
+		if err != nil {
+			if isObjectNotFound(err) {
+				return 0, nil, nil
+			}
+			return 0, nil, err
+		}
+
+		for _, x := range ans.Response.Candidate {
+			if x.Name != name {
+				continue
+			}
+
+			req := security.ReadInput{
+				Rulestack: stack,
+				RuleList:  rlist,
+				Priority:  x.Priority,
+				Candidate: true,
+			}
+
+			res, err := svc.Read(ctx, req)
+			if err != nil {
+				return 0, nil, err
+			}
+
+			return x.Priority, res.Response.Candidate, nil
+		}
+
+		if ans.Response.NextToken == "" {
+			return 0, nil, nil
+		}
+		input.NextToken = ans.Response.NextToken
+	}
+}
+
 // Schema handling.
 func securityRuleSchema(isResource bool, rmKeys []string) map[string]*schema.Schema {
-	action_values := []string{"Allow", "DenySilent", "DenyResetServer", "DenyResetBoth"}
-	decryption_values := []string{"", "SSLOutboundInspection"}
-
 	ans := map[string]*schema.Schema{
 		ConfigTypeName: configTypeSchema(),
 		RulestackName:  rsSchema(),
@@ -207,8 +517,29 @@ func securityRuleSchema(isResource bool, rmKeys []string) map[string]*schema.Sch
 			Type:        schema.TypeInt,
 			Required:    true,
 			Description: "The rule priority.",
-			ForceNew:    true,
 		},
+	}
+
+	for key, value := range securityRuleEntrySchema() {
+		ans[key] = value
+	}
+
+	for _, rmKey := range rmKeys {
+		delete(ans, rmKey)
+	}
+
+	if !isResource {
+		computed(ans, "", []string{ConfigTypeName, RulestackName, RuleListName, "priority"})
+	}
+
+	return ans
+}
+
+func securityRuleEntrySchema() map[string]*schema.Schema {
+	action_values := []string{"Allow", "DenySilent", "DenyResetServer", "DenyResetBoth"}
+	decryption_values := []string{"", "SSLOutboundInspection"}
+
+	return map[string]*schema.Schema{
 		"name": {
 			Type:        schema.TypeString,
 			Required:    true,
@@ -241,14 +572,7 @@ func securityRuleSchema(isResource bool, rmKeys []string) map[string]*schema.Sch
 							Type: schema.TypeString,
 						},
 					},
-					"countries": {
-						Type:        schema.TypeSet,
-						Optional:    true,
-						Description: "List of countries.",
-						Elem: &schema.Schema{
-							Type: schema.TypeString,
-						},
-					},
+					"countries": countrySchema(),
 					"feeds": {
 						Type:        schema.TypeSet,
 						Optional:    true,
@@ -289,14 +613,7 @@ func securityRuleSchema(isResource bool, rmKeys []string) map[string]*schema.Sch
 							Type: schema.TypeString,
 						},
 					},
-					"countries": {
-						Type:        schema.TypeSet,
-						Optional:    true,
-						Description: "List of countries.",
-						Elem: &schema.Schema{
-							Type: schema.TypeString,
-						},
-					},
+					"countries": countrySchema(),
 					"feeds": {
 						Type:        schema.TypeSet,
 						Optional:    true,
@@ -400,63 +717,66 @@ func securityRuleSchema(isResource bool, rmKeys []string) map[string]*schema.Sch
 			Description: "The update token.",
 		},
 	}
-
-	for _, rmKey := range rmKeys {
-		delete(ans, rmKey)
-	}
-
-	if !isResource {
-		computed(ans, "", []string{ConfigTypeName, RulestackName, RuleListName, "priority"})
-	}
-
-	return ans
 }
 
 func loadSecurityRule(d *schema.ResourceData) security.Info {
-	src := configFolder(d.Get("source"))
-	dst := configFolder(d.Get("destination"))
-	cat := configFolder(d.Get("category"))
-
 	return security.Info{
 		Rulestack: d.Get(RulestackName).(string),
 		RuleList:  d.Get(RuleListName).(string),
 		Priority:  d.Get("priority").(int),
-		Entry: security.Details{
-			Name:        d.Get("name").(string),
-			Description: d.Get("description").(string),
-			Enabled:     d.Get("enabled").(bool),
-			Source: security.SourceDetails{
-				Cidrs:       setToSlice(src["cidrs"]),
-				Countries:   setToSlice(src["countries"]),
-				Feeds:       setToSlice(src["feeds"]),
-				PrefixLists: setToSlice(src["prefix_lists"]),
-			},
-			NegateSource: d.Get("negate_source").(bool),
-			Destination: security.DestinationDetails{
-				Cidrs:       setToSlice(dst["cidrs"]),
-				Countries:   setToSlice(dst["countries"]),
-				Feeds:       setToSlice(dst["feeds"]),
-				PrefixLists: setToSlice(dst["prefix_lists"]),
-				FqdnLists:   setToSlice(dst["fqdn_lists"]),
-			},
-			NegateDestination: d.Get("negate_destination").(bool),
-			Applications:      setToSlice(d.Get("applications")),
-			Category: security.CategoryDetails{
-				UrlCategoryNames: setToSlice(cat["url_category_names"]),
-				Feeds:            setToSlice(cat["feeds"]),
-			},
-			Protocol:           d.Get("protocol").(string),
-			AuditComment:       d.Get("audit_comment").(string),
-			Action:             d.Get("action").(string),
-			Logging:            d.Get("logging").(bool),
-			DecryptionRuleType: d.Get("decryption_rule_type").(string),
-			//Tags: tlist,
-			//UpdateToken: d.Get("update_token").(string),
+		Entry:     loadSecurityRuleEntry(d.Get),
+	}
+}
+
+func loadSecurityRuleEntry(get func(string) interface{}) security.Details {
+	src := configFolder(get("source"))
+	dst := configFolder(get("destination"))
+	cat := configFolder(get("category"))
+
+	return security.Details{
+		Name:        get("name").(string),
+		Description: get("description").(string),
+		Enabled:     get("enabled").(bool),
+		Source: security.SourceDetails{
+			Cidrs:       setToSlice(src["cidrs"]),
+			Countries:   normalizeCountryCodes(setToSlice(src["countries"])),
+			Feeds:       setToSlice(src["feeds"]),
+			PrefixLists: setToSlice(src["prefix_lists"]),
 		},
+		NegateSource: get("negate_source").(bool),
+		Destination: security.DestinationDetails{
+			Cidrs:       setToSlice(dst["cidrs"]),
+			Countries:   normalizeCountryCodes(setToSlice(dst["countries"])),
+			Feeds:       setToSlice(dst["feeds"]),
+			PrefixLists: setToSlice(dst["prefix_lists"]),
+			FqdnLists:   setToSlice(dst["fqdn_lists"]),
+		},
+		NegateDestination: get("negate_destination").(bool),
+		Applications:      setToSlice(get("applications")),
+		Category: security.CategoryDetails{
+			UrlCategoryNames: setToSlice(cat["url_category_names"]),
+			Feeds:            setToSlice(cat["feeds"]),
+		},
+		Protocol:           get("protocol").(string),
+		AuditComment:       get("audit_comment").(string),
+		Action:             get("action").(string),
+		Logging:            get("logging").(bool),
+		DecryptionRuleType: get("decryption_rule_type").(string),
+		//Tags: tlist,
+		//UpdateToken: get("update_token").(string),
 	}
 }
 
 func saveSecurityRule(d *schema.ResourceData, stack, rlist string, priority int, o security.Details) {
+	d.Set(RulestackName, stack)
+	d.Set(RuleListName, rlist)
+	d.Set("priority", priority)
+	for key, value := range dumpSecurityRuleEntry(o) {
+		d.Set(key, value)
+	}
+}
+
+func dumpSecurityRuleEntry(o security.Details) map[string]interface{} {
 	src := map[string]interface{}{
 		"cidrs":        sliceToSet(o.Source.Cidrs),
 		"countries":    sliceToSet(o.Source.Countries),
@@ -475,42 +795,36 @@ func saveSecurityRule(d *schema.ResourceData, stack, rlist string, priority int,
 		"feeds":              sliceToSet(o.Category.Feeds),
 	}
 
-	d.Set(RulestackName, stack)
-	d.Set(RuleListName, rlist)
-	d.Set("priority", priority)
-	d.Set("name", o.Name)
-	d.Set("description", o.Description)
-	d.Set("enabled", o.Enabled)
-	d.Set("source", []interface{}{src})
-	d.Set("negate_source", o.NegateSource)
-	d.Set("destination", []interface{}{dst})
-	d.Set("negate_destination", o.NegateDestination)
-	d.Set("applications", sliceToSet(o.Applications))
-	d.Set("category", []interface{}{cat})
-	d.Set("protocol", o.Protocol)
-	d.Set("audit_comment", o.AuditComment)
-	d.Set("action", o.Action)
-	d.Set("logging", o.Logging)
-	d.Set("decryption_rule_type", o.DecryptionRuleType)
-	d.Set(TagsName, dumpTags(o.Tags))
-	d.Set("update_token", o.UpdateToken)
+	return map[string]interface{}{
+		"name":                 o.Name,
+		"description":          o.Description,
+		"enabled":              o.Enabled,
+		"source":               []interface{}{src},
+		"negate_source":        o.NegateSource,
+		"destination":          []interface{}{dst},
+		"negate_destination":   o.NegateDestination,
+		"applications":         sliceToSet(o.Applications),
+		"category":             []interface{}{cat},
+		"protocol":             o.Protocol,
+		"audit_comment":        o.AuditComment,
+		"action":               o.Action,
+		"logging":              o.Logging,
+		"decryption_rule_type": o.DecryptionRuleType,
+		TagsName:               dumpTags(o.Tags),
+		"update_token":         o.UpdateToken,
+	}
 }
 
 // Id functions.
-func buildSecurityRuleId(a, b string, c int) string {
-	return strings.Join([]string{a, b, strconv.Itoa(c)}, IdSeparator)
+func buildSecurityRuleId(a, b, c string) string {
+	return strings.Join([]string{a, b, c}, IdSeparator)
 }
 
-func parseSecurityRuleId(v string) (string, string, int, error) {
+func parseSecurityRuleId(v string) (string, string, string, error) {
 	tok := strings.Split(v, IdSeparator)
 	if len(tok) != 3 {
-		return "", "", 0, fmt.Errorf("Expecting 3 tokens, got %d", len(tok))
-	}
-
-	priority, err := strconv.Atoi(tok[2])
-	if err != nil {
-		return "", "", 0, err
+		return "", "", "", fmt.Errorf("Expecting 3 tokens, got %d", len(tok))
 	}
 
-	return tok[0], tok[1], priority, nil
+	return tok[0], tok[1], tok[2], nil
 }
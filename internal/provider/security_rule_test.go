@@ -1,14 +1,19 @@
 package provider
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/rule/security"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 // Data source.
@@ -199,8 +204,244 @@ func TestAccResourceSecurityRule(t *testing.T) {
 					),
 				),
 			},
+			{
+				Config: testAccSecurityRuleConfig(priority+1, o2),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"cloudngfwaws_security_rule.test", "priority", fmt.Sprintf("%d", priority+1),
+					),
+					resource.TestCheckResourceAttr(
+						"data.cloudngfwaws_security_rule.test", "priority", fmt.Sprintf("%d", priority+1),
+					),
+					resource.TestCheckResourceAttr(
+						"data.cloudngfwaws_security_rule.test", "name", o2.Name,
+					),
+					resource.TestCheckResourceAttr(
+						"data.cloudngfwaws_security_rule.test", "description", o2.Description,
+					),
+				),
+			},
+		},
+	})
+}
+
+func TestResourceSecurityRuleOffline(t *testing.T) {
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	l := newTestLifecycle(t, resourceSecurityRule(), m.meta())
+
+	raw := testSecurityRuleRaw("rs", "first", 3)
+	l.apply(raw)
+	l.check(map[string]string{
+		"id":       buildSecurityRuleId("rs", "LocalRule", "first"),
+		"priority": "3",
+		"name":     "first",
+		"action":   "Allow",
+	})
+
+	// Move the rule.
+	raw["priority"] = 7
+	raw["description"] = "moved"
+	l.apply(raw)
+	l.check(map[string]string{
+		"priority":    "7",
+		"description": "moved",
+	})
+	rules := m.Rulestack("rs").Rules["LocalRule"]
+	if rules[3].Candidate != nil {
+		t.Errorf("Priority 3 is still in use after the move")
+	}
+	if rules[7] == nil || rules[7].Candidate == nil {
+		t.Fatalf("Priority 7 is not in use after the move")
+	}
+
+	// Moving to a priority that stays in use times out and restores the
+	// original rule.
+	m.Rulestack("rs").Rules["LocalRule"][9] = &mockEntry{
+		Candidate: map[string]interface{}{"RuleName": "other"},
+	}
+	raw["priority"] = 9
+	raw["description"] = "not applied"
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err := moveSecurityRule(ctx, security.NewClient(m.client()), loadSecurityRuleFromRaw(t, raw), 7)
+	if err == nil || !strings.Contains(err.Error(), `in use by rule \"other\"`) {
+		t.Errorf("Moving onto an occupied priority: %v", err)
+	}
+	if rules[7].Candidate == nil {
+		t.Fatalf("Rule was not restored after a failed move")
+	}
+	if desc := rules[7].Candidate["Description"]; desc != "moved" {
+		t.Errorf("Restored rule has description %q, not the original", desc)
+	}
+
+	l.destroy()
+	if rules[7].Candidate != nil {
+		t.Fatalf("Rule was not deleted")
+	}
+}
+
+func TestResourceSecurityRuleUpgradeV0(t *testing.T) {
+	// The version 0 schema is the one priority based IDs were written with.
+	ty := resourceSecurityRuleV0().CoreConfigSchema().ImpliedType()
+	var attrs []string
+	for key := range ty.AttributeTypes() {
+		attrs = append(attrs, key)
+	}
+	sort.Strings(attrs)
+	want := "action applications audit_comment category decryption_rule_type description destination enabled id logging name negate_destination negate_source priority protocol rule_list rulestack source tags update_token"
+	if got := strings.Join(attrs, " "); got != want {
+		t.Errorf("Version 0 attributes changed:\n got %s\nwant %s", got, want)
+	}
+
+	state, err := upgradeSecurityRuleV0(context.Background(), map[string]interface{}{
+		"id":   buildSecurityRuleId("rs", "LocalRule", "3"),
+		"name": "first",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if state["id"] != buildSecurityRuleId("rs", "LocalRule", "first") {
+		t.Errorf("Upgraded id is %q", state["id"])
+	}
+}
+
+func TestResourceSecurityRulePriorityInUseOffline(t *testing.T) {
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	rules := m.Rulestack("rs").Rules
+	rules["LocalRule"] = map[int]*mockEntry{
+		5: {Candidate: map[string]interface{}{"RuleName": "other"}},
+	}
+
+	// A create waits for the rule holding the priority to move away.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		rules["LocalRule"][5].Candidate = nil
+	}()
+
+	l := newTestLifecycle(t, resourceSecurityRule(), m.meta())
+	l.apply(testSecurityRuleRaw("rs", "new", 5))
+	l.check(map[string]string{
+		"priority": "5",
+		"name":     "new",
+	})
+}
+
+func TestResourceSecurityRuleApplicationsOffline(t *testing.T) {
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	m.AppIdVersions["8509-7158"] = []string{"ssl"}
+	m.AppIdVersions["8510-7160"] = []string{"ssl", "web-browsing"}
+	l := newTestLifecycle(t, resourceSecurityRule(), m.meta())
+
+	// Unknown applications are rejected at plan time.
+	raw := testSecurityRuleRaw("rs", "first", 3)
+	raw["applications"] = []interface{}{"web-browsng"}
+	diags := l.applyDiags(raw)
+	if !diags.HasError() {
+		t.Fatalf("Unknown application was not rejected")
+	}
+	if msg := diagsToString(diags); !strings.Contains(msg, `did you mean "web-browsing"?`) || !strings.Contains(msg, `"8510-7160"`) {
+		t.Errorf("Got error %q", msg)
+	}
+	if l.state != nil && l.state.ID != "" {
+		t.Fatalf("Rule was created with an unknown application")
+	}
+
+	raw["applications"] = []interface{}{"web-browsing", "any"}
+	l.apply(raw)
+	l.check(map[string]string{
+		"applications.#": "2",
+	})
+
+	// Applications are checked against the version the NGFWs run.
+	fw := m.addFirewall("fw")
+	fw.Firewall["RuleStackName"] = "rs"
+	fw.Firewall["AppIdVersion"] = "8509-7158"
+	raw = testSecurityRuleRaw("rs", "second", 4)
+	raw["applications"] = []interface{}{"web-browsing"}
+	l = newTestLifecycle(t, resourceSecurityRule(), m.meta())
+	diags = l.applyDiags(raw)
+	if msg := diagsToString(diags); !diags.HasError() || !strings.Contains(msg, `"8509-7158"`) {
+		t.Errorf("Application missing from the running version was not rejected: %s", msg)
+	}
+}
+
+func TestResourceSecurityRuleCountriesOffline(t *testing.T) {
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	l := newTestLifecycle(t, resourceSecurityRule(), m.meta())
+
+	// Aliases are sent as their code, and do not cause a diff afterwards.
+	raw := testSecurityRuleRaw("rs", "first", 3)
+	raw["source"] = []interface{}{
+		map[string]interface{}{
+			"countries": []interface{}{"UK", "de"},
 		},
+	}
+	diags := l.r.Validate(terraform.NewResourceConfigRaw(raw))
+	if diags.HasError() || len(diags) != 2 {
+		t.Fatalf("Got diagnostics %q, expected two warnings", diagsToString(diags))
+	}
+	l.apply(raw)
+	l.check(map[string]string{
+		"source.0.countries.#": "2",
 	})
+	src, _ := m.Rulestack("rs").Rules["LocalRule"][3].Candidate["Source"].(map[string]interface{})
+	countries, _ := src["Countries"].([]interface{})
+	got := make([]string, 0, len(countries))
+	for _, x := range countries {
+		got = append(got, x.(string))
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != "DE,GB" {
+		t.Errorf("Sent countries %v", got)
+	}
+
+	// Misspelled codes are rejected.
+	raw["source"] = []interface{}{
+		map[string]interface{}{
+			"countries": []interface{}{"Frnace"},
+		},
+	}
+	diags = l.applyDiags(raw)
+	if !diags.HasError() || !strings.Contains(diagsToString(diags), `did you mean "FR" (France)?`) {
+		t.Errorf("Got diagnostics %q", diagsToString(diags))
+	}
+}
+
+func testSecurityRuleRaw(stack, name string, priority int) map[string]interface{} {
+	return map[string]interface{}{
+		RulestackName: stack,
+		RuleListName:  "LocalRule",
+		"priority":    priority,
+		"name":        name,
+		"source": []interface{}{
+			map[string]interface{}{
+				"cidrs": []interface{}{"any"},
+			},
+		},
+		"destination": []interface{}{
+			map[string]interface{}{
+				"cidrs": []interface{}{"192.168.0.0/16"},
+			},
+		},
+		"applications": []interface{}{"any"},
+		"category": []interface{}{
+			map[string]interface{}{
+				"url_category_names": []interface{}{"adult"},
+			},
+		},
+		"action": "Allow",
+	}
+}
+
+func loadSecurityRuleFromRaw(t *testing.T, raw map[string]interface{}) security.Info {
+	d := schema.TestResourceDataRaw(t, resourceSecurityRule().Schema, raw)
+	return loadSecurityRule(d)
 }
 
 func testAccSecurityRuleConfig(priority int, x security.Details) string {
@@ -0,0 +1,714 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/rule/security"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Data source.
+func dataSourceSecurityRules() *schema.Resource {
+	rule := securityRuleEntrySchema()
+	rule["priority"] = &schema.Schema{
+		Type:        schema.TypeInt,
+		Description: "The rule priority.",
+	}
+	computed(rule, "", nil)
+
+	return &schema.Resource{
+		Description: "Data source for retrieving every security rule in a rulebase.",
+
+		ReadContext: readSecurityRulesDataSource,
+
+		Schema: map[string]*schema.Schema{
+			ConfigTypeName: configTypeSchema(),
+			RulestackName:  rsSchema(),
+			RuleListName:   ruleListSchema(),
+			"rules": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The security rules, sorted by priority.",
+				Elem: &schema.Resource{
+					Schema: rule,
+				},
+			},
+		},
+	}
+}
+
+func readSecurityRulesDataSource(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := security.NewClient(con)
+
+	style := d.Get(ConfigTypeName).(string)
+	d.Set(ConfigTypeName, style)
+
+	stack := d.Get(RulestackName).(string)
+	rlist := d.Get(RuleListName).(string)
+
+	tflog.Info(
+		ctx, "read security rules",
+		"ds", true,
+		ConfigTypeName, style,
+		RulestackName, stack,
+		RuleListName, rlist,
+	)
+
+	list, err := readSecurityRuleList(ctx, svc, stack, rlist, style != RunningConfig)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	rules := make([]interface{}, 0, len(list))
+	for _, x := range list {
+		rule := dumpSecurityRuleEntry(x.Entry)
+		rule["priority"] = x.Priority
+		rules = append(rules, rule)
+	}
+
+	d.SetId(configTypeId(style, buildSecurityRulesId(stack, rlist)))
+	d.Set(RuleListName, rlist)
+	d.Set("rules", rules)
+
+	return nil
+}
+
+// Resource.
+func resourceSecurityRules() *schema.Resource {
+	return &schema.Resource{
+		Description: "Resource for managing every security rule in a rulebase as an ordered list.",
+
+		CreateContext: createUpdateSecurityRules,
+		ReadContext:   readSecurityRules,
+		UpdateContext: createUpdateSecurityRules,
+		DeleteContext: deleteSecurityRules,
+
+		CustomizeDiff: customdiff.All(
+			customizeDiffSecurityRules,
+			customizeDiffAutoCommit(RulestackName),
+		),
+
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(30 * time.Minute),
+			Update: schema.DefaultTimeout(30 * time.Minute),
+			Delete: schema.DefaultTimeout(30 * time.Minute),
+		},
+
+		Schema: securityRulesSchema(),
+	}
+}
+
+func createUpdateSecurityRules(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := security.NewClient(con)
+	stack := d.Get(RulestackName).(string)
+	rlist := d.Get(RuleListName).(string)
+	desired := loadSecurityRules(d)
+
+	names := make(map[string]bool, len(desired))
+	for _, x := range desired {
+		if names[x.Entry.Name] {
+			return diag.Errorf("Rule name %q is used more than once", x.Entry.Name)
+		}
+		names[x.Entry.Name] = true
+	}
+
+	tflog.Info(
+		ctx, "apply security rules",
+		RulestackName, stack,
+		RuleListName, rlist,
+		"count", len(desired),
+	)
+
+	current, err := readSecurityRuleList(ctx, svc, stack, rlist, true)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(buildSecurityRulesId(stack, rlist))
+
+	if err = applySecurityRules(ctx, svc, stack, rlist, current, desired); err != nil {
+		diags := readSecurityRules(ctx, d, meta)
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	return readSecurityRules(ctx, d, meta)
+}
+
+func readSecurityRules(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := security.NewClient(con)
+	stack, rlist, err := parseSecurityRulesId(d.Id())
+	if err != nil {
+		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
+	}
+
+	tflog.Info(
+		ctx, "read security rules",
+		RulestackName, stack,
+		RuleListName, rlist,
+	)
+
+	list, err := readSecurityRuleList(ctx, svc, stack, rlist, true)
+	if err != nil {
+		if isObjectNotFound(err) {
+			d.SetId("")
+			return nil
+		}
+		return diag.FromErr(err)
+	}
+
+	saveSecurityRules(d, stack, rlist, list)
+
+	return nil
+}
+
+func deleteSecurityRules(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := security.NewClient(con)
+	stack, rlist, err := parseSecurityRulesId(d.Id())
+	if err != nil {
+		return diag.Errorf("Error in parsing ID %q: %s", d.Id(), err)
+	}
+
+	tflog.Info(
+		ctx, "delete security rules",
+		RulestackName, stack,
+		RuleListName, rlist,
+	)
+
+	current, err := readSecurityRuleList(ctx, svc, stack, rlist, true)
+	if err != nil {
+		if isObjectNotFound(err) {
+			d.SetId("")
+			return nil
+		}
+		return diag.FromErr(err)
+	}
+
+	if err = applySecurityRules(ctx, svc, stack, rlist, current, nil); err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// customizeDiffSecurityRules checks the applications of each changed rule
+// against the App-ID catalog, and plans new priorities when rules are added,
+// removed or moved.
+func customizeDiffSecurityRules(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.HasChange("rule") {
+		return nil
+	}
+
+	// Rules only move when the order of the names changes, and which
+	// priorities they move to is only known once the rulebase is read.
+	if securityRuleNamesChanged(d) {
+		if err := d.SetNewComputed("priorities"); err != nil {
+			return err
+		}
+	}
+
+	if !d.NewValueKnown(RulestackName) {
+		return nil
+	}
+
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return err
+	}
+	stack := d.Get(RulestackName).(string)
+
+	for i := range d.Get("rule").([]interface{}) {
+		key := fmt.Sprintf("rule.%d.applications", i)
+		if !d.HasChange(key) || !d.NewValueKnown(key) {
+			continue
+		}
+		if err := checkApplications(ctx, meta, con, stack, setToSlice(d.Get(key))); err != nil {
+			return fmt.Errorf("rule %d: %s", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// securityRuleNamesChanged returns whether the plan adds, removes or reorders
+// any rules, or if that is not known yet.
+func securityRuleNamesChanged(d *schema.ResourceDiff) bool {
+	if !d.NewValueKnown("rule") {
+		return true
+	}
+
+	prev, _ := d.GetChange("rule")
+	old := prev.([]interface{})
+	rules := d.Get("rule").([]interface{})
+	if len(old) != len(rules) {
+		return true
+	}
+	for i := range rules {
+		key := fmt.Sprintf("rule.%d.name", i)
+		if !d.NewValueKnown(key) {
+			return true
+		}
+		x, _ := old[i].(map[string]interface{})
+		if x == nil || x["name"] != d.Get(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Rulebase diffing.
+
+// The spacing between the priorities of rules added to the end of the
+// rulebase, which leaves room to insert rules between them later.
+const securityRulePriorityStep = 10
+
+// applySecurityRules transforms the current rulebase into the desired one,
+// matching rules up by name.  Rules keep their current priorities wherever
+// the order allows, so only new and moved rules are created, each at a
+// priority that no current rule uses.  The old locations of moved rules and
+// the unwanted rules are deleted first, so that no two rules ever share a
+// name, then the new and moved rules are created.  If a delete or create
+// fails, the rules created so far are deleted and the deleted rules are
+// restored, leaving the rulebase as it was.  The rules that stayed in place
+// but whose config differs are updated last.
+func applySecurityRules(ctx context.Context, svc *security.Client, stack, rlist string, current, desired []security.Info) error {
+	desired = planSecurityRules(current, desired)
+
+	want := make(map[string]security.Info, len(desired))
+	for _, x := range desired {
+		want[x.Entry.Name] = x
+	}
+
+	var creates, updates []security.Info
+	have := make(map[string]security.Info, len(current))
+	for _, x := range current {
+		have[x.Entry.Name] = x
+	}
+	for _, x := range desired {
+		if y, ok := have[x.Entry.Name]; !ok || y.Priority != x.Priority {
+			creates = append(creates, x)
+		} else if !securityRuleEntryEqual(y.Entry, x.Entry) {
+			updates = append(updates, x)
+		}
+	}
+
+	var deleted, created []security.Info
+	undo := func(err error) error {
+		var errs []string
+
+		// Put the rulebase back, even if ctx has expired.
+		for i := len(created) - 1; i >= 0; i-- {
+			x := created[i]
+			tflog.Info(
+				ctx, "undo security rule create",
+				RulestackName, stack,
+				RuleListName, rlist,
+				"priority", x.Priority,
+				"name", x.Entry.Name,
+			)
+			if err := deleteSecurityRuleAt(context.Background(), svc, x); err != nil {
+				errs = append(errs, fmt.Sprintf("deleting rule %q at priority %d: %s", x.Entry.Name, x.Priority, err))
+			}
+		}
+		for _, x := range deleted {
+			tflog.Info(
+				ctx, "undo security rule delete",
+				RulestackName, stack,
+				RuleListName, rlist,
+				"priority", x.Priority,
+				"name", x.Entry.Name,
+			)
+			if err := svc.Create(context.Background(), x); err != nil {
+				errs = append(errs, fmt.Sprintf("restoring rule %q at priority %d: %s", x.Entry.Name, x.Priority, err))
+			}
+		}
+
+		if len(errs) != 0 {
+			return fmt.Errorf("%s (undoing the changes also failed: %s)", err, strings.Join(errs, "; "))
+		}
+		return err
+	}
+
+	for _, x := range current {
+		if y, ok := want[x.Entry.Name]; ok && y.Priority == x.Priority {
+			continue
+		}
+
+		// Delete the rule (or the old location of a moved rule).
+		tflog.Info(
+			ctx, "delete security rule",
+			RulestackName, stack,
+			RuleListName, rlist,
+			"priority", x.Priority,
+			"name", x.Entry.Name,
+		)
+		if err := svc.Delete(ctx, stack, rlist, x.Priority); err != nil && !isObjectNotFound(err) {
+			return undo(fmt.Errorf("Error deleting rule %q at priority %d: %s", x.Entry.Name, x.Priority, err))
+		}
+		deleted = append(deleted, x)
+	}
+
+	for _, x := range creates {
+		tflog.Info(
+			ctx, "create security rule",
+			RulestackName, stack,
+			RuleListName, rlist,
+			"priority", x.Priority,
+			"name", x.Entry.Name,
+		)
+		if err := svc.Create(ctx, x); err != nil {
+			return undo(fmt.Errorf("Error creating rule %q at priority %d: %s", x.Entry.Name, x.Priority, err))
+		}
+		created = append(created, x)
+	}
+
+	for _, x := range updates {
+		tflog.Info(
+			ctx, "update security rule",
+			RulestackName, stack,
+			RuleListName, rlist,
+			"priority", x.Priority,
+			"name", x.Entry.Name,
+		)
+		if err := svc.Update(ctx, x); err != nil {
+			return fmt.Errorf("Error updating rule %q at priority %d: %s", x.Entry.Name, x.Priority, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteSecurityRuleAt deletes the given rule from its priority, as long as
+// that priority still holds a rule of the same name.
+func deleteSecurityRuleAt(ctx context.Context, svc *security.Client, o security.Info) error {
+	req := security.ReadInput{
+		Rulestack: o.Rulestack,
+		RuleList:  o.RuleList,
+		Priority:  o.Priority,
+		Candidate: true,
+	}
+
+	res, err := svc.Read(ctx, req)
+	if err != nil {
+		if isObjectNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if res.Response.Candidate == nil {
+		return nil
+	}
+	if name := res.Response.Candidate.Name; name != o.Entry.Name {
+		return fmt.Errorf("priority %d is in use by rule %q", o.Priority, name)
+	}
+
+	if err = svc.Delete(ctx, o.Rulestack, o.RuleList, o.Priority); err != nil && !isObjectNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// planSecurityRules returns the desired rules with their priorities set.
+// The most rules whose current priorities are already in the desired order
+// stay where they are, and the rest go to the free priorities between them.
+// If there is no room between two of the rules that stay, the latter one is
+// moved as well.
+func planSecurityRules(current, desired []security.Info) []security.Info {
+	have := make(map[string]int, len(current))
+	used := make(map[int]bool, len(current))
+	for _, x := range current {
+		have[x.Entry.Name] = x.Priority
+		used[x.Priority] = true
+	}
+
+	ans := make([]security.Info, len(desired))
+	copy(ans, desired)
+	kept := keptSecurityRules(have, ans)
+
+	for done := false; !done; {
+		done = true
+		start, lo := 0, 0
+		for i := range ans {
+			if !kept[i] {
+				continue
+			}
+			hi := have[ans[i].Entry.Name]
+			if !fillSecurityRulePriorities(ans[start:i], lo, hi, used) {
+				kept[i], done = false, false
+				break
+			}
+			ans[i].Priority = hi
+			start, lo = i+1, hi
+		}
+		if done {
+			fillSecurityRulePriorities(ans[start:], lo, 0, used)
+		}
+	}
+
+	return ans
+}
+
+// keptSecurityRules returns which of the desired rules can keep their current
+// priorities: the longest run of rules (not necessarily adjacent) whose
+// current priorities increase in the desired order.
+func keptSecurityRules(have map[string]int, desired []security.Info) []bool {
+	// The index of the rule ending the best run of each length, and the
+	// rule before each rule in its run.
+	var ends []int
+	prev := make([]int, len(desired))
+	for i, x := range desired {
+		priority, ok := have[x.Entry.Name]
+		if !ok {
+			continue
+		}
+		n := sort.Search(len(ends), func(j int) bool {
+			return have[desired[ends[j]].Entry.Name] >= priority
+		})
+		prev[i] = -1
+		if n > 0 {
+			prev[i] = ends[n-1]
+		}
+		if n == len(ends) {
+			ends = append(ends, i)
+		} else {
+			ends[n] = i
+		}
+	}
+
+	kept := make([]bool, len(desired))
+	if len(ends) > 0 {
+		for i := ends[len(ends)-1]; i >= 0; i = prev[i] {
+			kept[i] = true
+		}
+	}
+
+	return kept
+}
+
+// fillSecurityRulePriorities spreads the rules over the free priorities
+// between lo and hi, or after lo if hi is 0, returning false if they do
+// not fit.
+func fillSecurityRulePriorities(rules []security.Info, lo, hi int, used map[int]bool) bool {
+	last := lo
+	for i := range rules {
+		priority := lo + (i+1)*securityRulePriorityStep
+		if hi != 0 {
+			priority = lo + (i+1)*(hi-lo)/(len(rules)+1)
+		}
+		if priority <= last {
+			priority = last + 1
+		}
+		for used[priority] {
+			priority++
+		}
+		if hi != 0 && priority >= hi {
+			return false
+		}
+		rules[i].Priority = priority
+		last = priority
+	}
+
+	return true
+}
+
+// readSecurityRuleList returns every rule in the rulebase, sorted by priority.
+func readSecurityRuleList(ctx context.Context, svc *security.Client, stack, rlist string, candidate bool) ([]security.Info, error) {
+	input := security.ListInput{
+		Rulestack:  stack,
+		RuleList:   rlist,
+		Candidate:  candidate,
+		Running:    !candidate,
+		MaxResults: 1000,
+	}
+
+	var ans []security.Info
+	for {
+		res, err := svc.List(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		entries := res.Response.Candidate
+		if !candidate {
+			entries = res.Response.Running
+		}
+
+		for _, x := range entries {
+			req := security.ReadInput{
+				Rulestack: stack,
+				RuleList:  rlist,
+				Priority:  x.Priority,
+				Candidate: candidate,
+				Running:   !candidate,
+			}
+
+			rule, err := svc.Read(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+
+			info := security.Info{
+				Rulestack: stack,
+				RuleList:  rlist,
+				Priority:  x.Priority,
+			}
+			if candidate {
+				info.Entry = *rule.Response.Candidate
+			} else {
+				info.Entry = *rule.Response.Running
+			}
+			ans = append(ans, info)
+		}
+
+		if res.Response.NextToken == "" {
+			break
+		}
+		input.NextToken = res.Response.NextToken
+	}
+
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].Priority < ans[j].Priority
+	})
+
+	return ans, nil
+}
+
+// securityRuleEntryEqual compares the user configurable parts of two rules.
+func securityRuleEntryEqual(a, b security.Details) bool {
+	return reflect.DeepEqual(normalizeSecurityRuleEntry(a), normalizeSecurityRuleEntry(b))
+}
+
+func normalizeSecurityRuleEntry(o security.Details) security.Details {
+	o.Source.Cidrs = sortedSlice(o.Source.Cidrs)
+	o.Source.Countries = sortedSlice(o.Source.Countries)
+	o.Source.Feeds = sortedSlice(o.Source.Feeds)
+	o.Source.PrefixLists = sortedSlice(o.Source.PrefixLists)
+	o.Destination.Cidrs = sortedSlice(o.Destination.Cidrs)
+	o.Destination.Countries = sortedSlice(o.Destination.Countries)
+	o.Destination.Feeds = sortedSlice(o.Destination.Feeds)
+	o.Destination.PrefixLists = sortedSlice(o.Destination.PrefixLists)
+	o.Destination.FqdnLists = sortedSlice(o.Destination.FqdnLists)
+	o.Applications = sortedSlice(o.Applications)
+	o.Category.UrlCategoryNames = sortedSlice(o.Category.UrlCategoryNames)
+	o.Category.Feeds = sortedSlice(o.Category.Feeds)
+	o.Tags = nil
+	o.UpdateToken = ""
+
+	return o
+}
+
+func sortedSlice(v []string) []string {
+	if len(v) == 0 {
+		return nil
+	}
+
+	ans := make([]string, len(v))
+	copy(ans, v)
+	sort.Strings(ans)
+
+	return ans
+}
+
+// Schema handling.
+func securityRulesSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		RulestackName: rsSchema(),
+		RuleListName:  ruleListSchema(),
+		"rule": {
+			Type:        schema.TypeList,
+			Required:    true,
+			Description: "The ordered list of security rules.  Rules in this rulebase that are not in this list are deleted.",
+			MinItems:    1,
+			Elem: &schema.Resource{
+				Schema: securityRuleEntrySchema(),
+			},
+		},
+		"priorities": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "The priority of each rule, by rule name.  Rules keep their priorities while the order allows, and new and moved rules are given free priorities between their neighbors, with rules added to the end spaced 10 apart.",
+			Elem: &schema.Schema{
+				Type: schema.TypeInt,
+			},
+		},
+	}
+}
+
+func loadSecurityRules(d *schema.ResourceData) []security.Info {
+	stack := d.Get(RulestackName).(string)
+	rlist := d.Get(RuleListName).(string)
+	list := d.Get("rule").([]interface{})
+
+	ans := make([]security.Info, 0, len(list))
+	for _, v := range list {
+		x := v.(map[string]interface{})
+		ans = append(ans, security.Info{
+			Rulestack: stack,
+			RuleList:  rlist,
+			Entry: loadSecurityRuleEntry(func(key string) interface{} {
+				return x[key]
+			}),
+		})
+	}
+
+	return ans
+}
+
+func saveSecurityRules(d *schema.ResourceData, stack, rlist string, list []security.Info) {
+	rules := make([]interface{}, 0, len(list))
+	priorities := make(map[string]interface{}, len(list))
+	for _, x := range list {
+		rules = append(rules, dumpSecurityRuleEntry(x.Entry))
+		priorities[x.Entry.Name] = x.Priority
+	}
+
+	d.Set(RulestackName, stack)
+	d.Set(RuleListName, rlist)
+	d.Set("rule", rules)
+	d.Set("priorities", priorities)
+}
+
+// Id functions.
+func buildSecurityRulesId(a, b string) string {
+	return strings.Join([]string{a, b}, IdSeparator)
+}
+
+func parseSecurityRulesId(v string) (string, string, error) {
+	tok := strings.Split(v, IdSeparator)
+	if len(tok) != 2 {
+		return "", "", fmt.Errorf("Expecting 2 tokens, got %d", len(tok))
+	}
+
+	return tok[0], tok[1], nil
+}
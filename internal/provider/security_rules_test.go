@@ -0,0 +1,311 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// Resource.
+func TestAccResourceSecurityRules(t *testing.T) {
+	n1 := fmt.Sprintf("tf%s", acctest.RandString(8))
+	n2 := fmt.Sprintf("tf%s", acctest.RandString(8))
+	n3 := fmt.Sprintf("tf%s", acctest.RandString(8))
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSecurityRulesConfig([]string{n1, n2}),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"cloudngfwaws_security_rules.test", "rule.#", "2",
+					),
+					resource.TestCheckResourceAttr(
+						"cloudngfwaws_security_rules.test", "rule.0.name", n1,
+					),
+					resource.TestCheckResourceAttr(
+						"cloudngfwaws_security_rules.test", "priorities."+n1, "10",
+					),
+					resource.TestCheckResourceAttr(
+						"cloudngfwaws_security_rules.test", "rule.1.name", n2,
+					),
+					resource.TestCheckResourceAttr(
+						"cloudngfwaws_security_rules.test", "priorities."+n2, "20",
+					),
+				),
+			},
+			{
+				Config: testAccSecurityRulesConfig([]string{n3, n2, n1}),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"cloudngfwaws_security_rules.test", "rule.#", "3",
+					),
+					resource.TestCheckResourceAttr(
+						"cloudngfwaws_security_rules.test", "rule.0.name", n3,
+					),
+					resource.TestCheckResourceAttr(
+						"cloudngfwaws_security_rules.test", "rule.1.name", n2,
+					),
+					resource.TestCheckResourceAttr(
+						"cloudngfwaws_security_rules.test", "rule.2.name", n1,
+					),
+				),
+			},
+			{
+				Config: testAccSecurityRulesConfig([]string{n1}),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(
+						"cloudngfwaws_security_rules.test", "rule.#", "1",
+					),
+					resource.TestCheckResourceAttr(
+						"cloudngfwaws_security_rules.test", "rule.0.name", n1,
+					),
+				),
+			},
+		},
+	})
+}
+
+func TestResourceSecurityRulesOffline(t *testing.T) {
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	l := newTestLifecycle(t, resourceSecurityRules(), m.meta())
+
+	steps := [][]string{
+		{"a", "b"},
+		{"c", "b", "a"},
+		{"b", "a"},
+		{"a"},
+	}
+
+	for _, names := range steps {
+		l.apply(testSecurityRulesRaw("rs", names))
+
+		want := map[string]string{
+			"rule.#": strconv.Itoa(len(names)),
+		}
+		for i, name := range names {
+			want[fmt.Sprintf("rule.%d.name", i)] = name
+		}
+		l.check(want)
+		testSecurityRulesOrdered(t, l, len(names))
+
+		if n := len(testMockRuleNames(m, "rs", "LocalRule")); n != len(names) {
+			t.Fatalf("Rulebase has %d rules, expected %d", n, len(names))
+		}
+	}
+
+	// Duplicate names are rejected.
+	if diags := l.applyDiags(testSecurityRulesRaw("rs", []string{"a", "a"})); !diags.HasError() {
+		t.Errorf("Duplicate rule names were accepted")
+	}
+
+	l.destroy()
+	if n := len(testMockRuleNames(m, "rs", "LocalRule")); n != 0 {
+		t.Fatalf("Rulebase still has %d rules", n)
+	}
+}
+
+func TestResourceSecurityRulesInsertOffline(t *testing.T) {
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	l := newTestLifecycle(t, resourceSecurityRules(), m.meta())
+
+	names := make([]string, 0, 21)
+	for i := 0; i < 20; i++ {
+		names = append(names, fmt.Sprintf("r%d", i))
+	}
+	l.apply(testSecurityRulesRaw("rs", names))
+	before := l.get("priorities.r10")
+
+	// Inserting a rule only creates that rule.
+	names = append(names[:5], append([]string{"new"}, names[5:]...)...)
+	m.Requests = nil
+	l.apply(testSecurityRulesRaw("rs", names))
+
+	var creates, deletes int
+	for _, req := range m.Requests {
+		switch {
+		case strings.HasPrefix(req, "POST ") && strings.Contains(req, "/priorities/"):
+			creates++
+		case strings.HasPrefix(req, "DELETE "):
+			deletes++
+		}
+	}
+	if creates != 1 || deletes != 0 {
+		t.Errorf("Got %d creates and %d deletes, expected 1 create: %v", creates, deletes, m.Requests)
+	}
+	if l.get("rule.5.name") != "new" || l.get("priorities.r10") != before {
+		t.Errorf("Rule was not inserted in place: %v", testMockRuleNames(m, "rs", "LocalRule"))
+	}
+	testSecurityRulesOrdered(t, l, len(names))
+
+}
+
+func TestResourceSecurityRulesFailedCreateOffline(t *testing.T) {
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	l := newTestLifecycle(t, resourceSecurityRules(), m.meta())
+	l.apply(testSecurityRulesRaw("rs", []string{"a", "b"}))
+	before := fmt.Sprint(testMockRuleNames(m, "rs", "LocalRule"))
+
+	// Moving b to the top deletes it from priority 20 and creates it at
+	// priority 5, then the create of c fails, so b is moved back.
+	m.FailWrites = map[string]int{"/priorities/21": http.StatusBadRequest}
+	if diags := l.applyDiags(testSecurityRulesRaw("rs", []string{"b", "a", "c"})); !diags.HasError() {
+		t.Fatalf("Failed create did not return an error")
+	}
+	if after := fmt.Sprint(testMockRuleNames(m, "rs", "LocalRule")); after != before {
+		t.Errorf("Rulebase is %s after the failed create, expected %s", after, before)
+	}
+
+	// Removing both rules deletes a, then the delete of b fails, so a is
+	// restored.
+	m.FailWrites = map[string]int{"/priorities/20": http.StatusBadRequest}
+	if diags := l.applyDiags(testSecurityRulesRaw("rs", []string{"c"})); !diags.HasError() {
+		t.Fatalf("Failed delete did not return an error")
+	}
+	if after := fmt.Sprint(testMockRuleNames(m, "rs", "LocalRule")); after != before {
+		t.Errorf("Rulebase is %s after the failed delete, expected %s", after, before)
+	}
+}
+
+func TestResourceSecurityRulesPlanPrioritiesOffline(t *testing.T) {
+	m := newMockApi(t)
+	m.addRulestack("rs")
+	l := newTestLifecycle(t, resourceSecurityRules(), m.meta())
+	l.apply(testSecurityRulesRaw("rs", []string{"a", "b"}))
+
+	// Changing a rule in place keeps the priorities.
+	raw := testSecurityRulesRaw("rs", []string{"a", "b"})
+	raw["rule"].([]interface{})[0].(map[string]interface{})["description"] = "changed"
+	diff, err := l.r.Diff(context.Background(), l.state, terraform.NewResourceConfigRaw(raw), l.meta)
+	if err != nil {
+		t.Fatalf("Error planning: %s", err)
+	}
+	if attr := diff.Attributes["priorities.%"]; attr != nil && attr.NewComputed {
+		t.Errorf("Priorities are unknown after an in place change")
+	}
+
+	// Moving a rule makes them unknown until the apply.
+	diff, err = l.r.Diff(context.Background(), l.state, terraform.NewResourceConfigRaw(testSecurityRulesRaw("rs", []string{"b", "a"})), l.meta)
+	if err != nil {
+		t.Fatalf("Error planning: %s", err)
+	}
+	if attr := diff.Attributes["priorities.%"]; attr == nil || !attr.NewComputed {
+		t.Errorf("Priorities are known after a move: %#v", diff.Attributes)
+	}
+}
+
+// testSecurityRulesOrdered checks that the rule priorities increase in list
+// order.
+func testSecurityRulesOrdered(t *testing.T, l *testLifecycle, n int) {
+	t.Helper()
+
+	last := 0
+	for i := 0; i < n; i++ {
+		v := l.get("priorities." + l.get(fmt.Sprintf("rule.%d.name", i)))
+		priority, err := strconv.Atoi(v)
+		if err != nil || priority <= last {
+			t.Fatalf("Rule %d has priority %q after priority %d", i, v, last)
+		}
+		last = priority
+	}
+}
+
+func testSecurityRulesRaw(stack string, names []string) map[string]interface{} {
+	rules := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		rule := testSecurityRuleRaw(stack, name, 0)
+		delete(rule, RulestackName)
+		delete(rule, RuleListName)
+		delete(rule, "priority")
+		rules = append(rules, rule)
+	}
+
+	return map[string]interface{}{
+		RulestackName: stack,
+		RuleListName:  "LocalRule",
+		"rule":        rules,
+	}
+}
+
+// testMockRuleNames returns the candidate rule names by priority.
+func testMockRuleNames(m *mockApi, stack, rlist string) map[int]string {
+	ans := make(map[int]string)
+	for priority, e := range m.Rulestack(stack).Rules[rlist] {
+		if e.Candidate != nil {
+			ans[priority], _ = e.Candidate["RuleName"].(string)
+		}
+	}
+
+	return ans
+}
+
+func TestDataSourceSecurityRulesOffline(t *testing.T) {
+	m := newMockApi(t)
+	m.addRulestack("rs")
+
+	l := newTestLifecycle(t, resourceSecurityRules(), m.meta())
+	l.apply(testSecurityRulesRaw("rs", []string{"b", "a", "c"}))
+
+	ds := testReadDataSource(t, dataSourceSecurityRules(), m.meta(), map[string]interface{}{
+		RulestackName: "rs",
+		RuleListName:  "LocalRule",
+	})
+
+	want := map[string]string{
+		"rules.#":          "3",
+		"rules.0.name":     "b",
+		"rules.0.priority": "10",
+		"rules.2.name":     "c",
+		"rules.2.priority": "30",
+		"rules.2.action":   "Allow",
+	}
+	for key, value := range want {
+		if ds[key] != value {
+			t.Errorf("%s: got %q, expected %q", key, ds[key], value)
+		}
+	}
+}
+
+func testAccSecurityRulesConfig(names []string) string {
+	var buf strings.Builder
+
+	buf.WriteString(testAccRulestackConfig("r", nil))
+
+	buf.WriteString(fmt.Sprintf(`
+resource "cloudngfwaws_security_rules" "test" {
+    %s = cloudngfwaws_rulestack.r.name
+    %s = "LocalRule"`, RulestackName, RuleListName))
+
+	for _, name := range names {
+		buf.WriteString(fmt.Sprintf(`
+    rule {
+        name = %q
+        source {
+            cidrs = ["any"]
+        }
+        destination {
+            cidrs = ["192.168.0.0/16"]
+        }
+        applications = ["any"]
+        category {}
+        action = "Allow"
+    }`, name))
+	}
+
+	buf.WriteString(`
+}`)
+
+	return buf.String()
+}
@@ -1,7 +1,16 @@
 package provider
 
 import (
+	"context"
+	"encoding/json"
 	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 func sliceToString(v []string) string {
@@ -21,3 +30,172 @@ func sliceToString(v []string) string {
 
 	return buf.String()
 }
+
+// testLifecycle drives a single resource through plan, apply, refresh and
+// destroy the same way Terraform does, but in-process and without the
+// Terraform CLI, so that it can run against the mock API.
+type testLifecycle struct {
+	t     *testing.T
+	r     *schema.Resource
+	meta  interface{}
+	state *terraform.InstanceState
+}
+
+func newTestLifecycle(t *testing.T, r *schema.Resource, meta interface{}) *testLifecycle {
+	return &testLifecycle{
+		t:    t,
+		r:    r,
+		meta: meta,
+	}
+}
+
+// apply plans and applies the given config, then verifies that a refresh
+// followed by another plan shows no changes.
+func (l *testLifecycle) apply(raw map[string]interface{}) {
+	l.t.Helper()
+
+	if diags := l.applyDiags(raw); diags.HasError() {
+		l.t.Fatalf("Error applying config: %s", diagsToString(diags))
+	}
+
+	if l.state == nil || l.state.ID == "" {
+		l.t.Fatalf("Resource is gone after apply")
+	}
+
+	diff, err := l.r.Diff(context.Background(), l.state, terraform.NewResourceConfigRaw(raw), l.meta)
+	if err != nil {
+		l.t.Fatalf("Error planning config after apply: %s", err)
+	}
+	if diff != nil && !diff.Empty() {
+		l.t.Fatalf("Plan is not empty after apply: %#v", diff.Attributes)
+	}
+}
+
+// applyDiags plans, applies and refreshes the given config, returning any
+// diagnostics instead of failing the test.
+func (l *testLifecycle) applyDiags(raw map[string]interface{}) diag.Diagnostics {
+	l.t.Helper()
+	ctx := context.Background()
+	cfg := terraform.NewResourceConfigRaw(raw)
+
+	if diags := l.r.Validate(cfg); diags.HasError() {
+		return diags
+	}
+
+	diff, err := l.r.Diff(ctx, l.state, cfg, l.meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	var diags diag.Diagnostics
+	if diff != nil && !diff.Empty() {
+		diff.RawConfig = l.rawConfig(raw)
+		l.state, diags = l.r.Apply(ctx, l.state, diff, l.meta)
+		if diags.HasError() || l.state == nil || l.state.ID == "" {
+			return diags
+		}
+	}
+
+	return append(diags, l.refresh()...)
+}
+
+// rawConfig returns the config as Terraform sends it along with a change, or
+// a null value if the config does not convert.
+func (l *testLifecycle) rawConfig(raw map[string]interface{}) cty.Value {
+	ty := l.r.CoreConfigSchema().ImpliedType()
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return cty.NullVal(ty)
+	}
+	val, err := ctyjson.Unmarshal(data, ty)
+	if err != nil {
+		return cty.NullVal(ty)
+	}
+
+	return val
+}
+
+// refresh reads the resource into state, as done before every plan.
+func (l *testLifecycle) refresh() diag.Diagnostics {
+	if l.state == nil {
+		return nil
+	}
+
+	state, diags := l.r.RefreshWithoutUpgrade(context.Background(), l.state, l.meta)
+	if !diags.HasError() {
+		l.state = state
+	}
+
+	return diags
+}
+
+// destroy deletes the resource.
+func (l *testLifecycle) destroy() {
+	l.t.Helper()
+
+	if l.state == nil {
+		return
+	}
+
+	diff := &terraform.InstanceDiff{Destroy: true}
+	state, diags := l.r.Apply(context.Background(), l.state, diff, l.meta)
+	if diags.HasError() {
+		l.t.Fatalf("Error destroying resource: %s", diagsToString(diags))
+	}
+	l.state = state
+}
+
+// get returns the flatmapped state attribute at key.
+func (l *testLifecycle) get(key string) string {
+	if l.state == nil {
+		return ""
+	}
+
+	return l.state.Attributes[key]
+}
+
+// check verifies flatmapped state attributes.
+func (l *testLifecycle) check(want map[string]string) {
+	l.t.Helper()
+
+	for key, value := range want {
+		if got := l.get(key); got != value {
+			l.t.Errorf("%s: got %q, expected %q", key, got, value)
+		}
+	}
+}
+
+// testReadDataSource reads a data source with the given config.
+func testReadDataSource(t *testing.T, r *schema.Resource, meta interface{}, raw map[string]interface{}) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+
+	diff, err := r.Diff(ctx, nil, terraform.NewResourceConfigRaw(raw), meta)
+	if err != nil {
+		t.Fatalf("Error planning data source: %s", err)
+	}
+
+	state, diags := r.ReadDataApply(ctx, diff, meta)
+	if diags.HasError() {
+		t.Fatalf("Error reading data source: %s", diagsToString(diags))
+	}
+	if state == nil {
+		return nil
+	}
+
+	return state.Attributes
+}
+
+func diagsToString(diags diag.Diagnostics) string {
+	msgs := make([]string, 0, len(diags))
+	for _, x := range diags {
+		if x.Detail != "" {
+			msgs = append(msgs, x.Summary+": "+x.Detail)
+		} else {
+			msgs = append(msgs, x.Summary)
+		}
+	}
+
+	return strings.Join(msgs, "; ")
+}
@@ -118,6 +118,48 @@ func computed(sm map[string]*schema.Schema, parent string, omits []string) {
 	}
 }
 
+// listAll calls page with each pagination token in turn, starting with an
+// empty one, until no next token is returned.
+func listAll(page func(token string) ([]string, string, error)) ([]string, error) {
+	var ans []string
+
+	_, err := readPages("", true, 0, func(token string) (int, string, error) {
+		items, next, err := page(token)
+		ans = append(ans, items...)
+		return len(items), next, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ans, nil
+}
+
+// readPages calls page with token and, if all is set, with each following
+// next token until there are no more pages, returning the last next token.
+// When reading all pages, once more than limit results have been read (if
+// limit is positive), an error is returned instead.
+func readPages(token string, all bool, limit int, page func(token string) (int, string, error)) (string, error) {
+	var total int
+
+	for {
+		count, next, err := page(token)
+		if err != nil {
+			return "", err
+		}
+
+		total += count
+		if all && limit > 0 && total > limit {
+			return "", fmt.Errorf("Got more than %d results, increase max_total_results to retrieve them all", limit)
+		}
+
+		if !all || next == "" || next == token {
+			return next, nil
+		}
+		token = next
+	}
+}
+
 func isObjectNotFound(e error) bool {
 	if e2, ok := e.(*api.Status); ok {
 		return e2.ObjectNotFound()
@@ -125,3 +167,47 @@ func isObjectNotFound(e error) bool {
 
 	return false
 }
+
+// closestMatch returns the value that is the fewest edits away from s, with
+// case differences not counting as edits.
+func closestMatch(s string, values []string) string {
+	var ans string
+	best := -1
+	for _, v := range values {
+		if n := editDistance(strings.ToLower(s), strings.ToLower(v)); best < 0 || n < best || (n == best && v < ans) {
+			ans, best = v, n
+		}
+	}
+
+	return ans
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	x, y := []rune(a), []rune(b)
+	prev := make([]int, len(y)+1)
+	cur := make([]int, len(y)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(x); i++ {
+		cur[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if n := prev[j] + 1; n < cur[j] {
+				cur[j] = n
+			}
+			if n := cur[j-1] + 1; n < cur[j] {
+				cur[j] = n
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(y)]
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestListAll(t *testing.T) {
+	pages := map[string][]string{
+		"":   {"a", "b"},
+		"t1": {"c"},
+		"t2": {"d", "e"},
+	}
+	next := map[string]string{
+		"":   "t1",
+		"t1": "t2",
+	}
+
+	var tokens []string
+	ans, err := listAll(func(token string) ([]string, string, error) {
+		tokens = append(tokens, token)
+		return pages[token], next[token], nil
+	})
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	if !reflect.DeepEqual(ans, []string{"a", "b", "c", "d", "e"}) {
+		t.Errorf("Got %#v", ans)
+	}
+	if !reflect.DeepEqual(tokens, []string{"", "t1", "t2"}) {
+		t.Errorf("Requested tokens %#v", tokens)
+	}
+
+	if _, err = listAll(func(token string) ([]string, string, error) {
+		return nil, "", fmt.Errorf("boom")
+	}); err == nil {
+		t.Errorf("Error was not returned")
+	}
+}
+
+func TestReadPages(t *testing.T) {
+	next := map[string]string{
+		"":   "t1",
+		"t1": "t2",
+		"t2": "",
+	}
+	page := func(calls *int) func(string) (int, string, error) {
+		return func(token string) (int, string, error) {
+			*calls++
+			return 10, next[token], nil
+		}
+	}
+
+	var calls int
+	token, err := readPages("", false, 0, page(&calls))
+	if err != nil || token != "t1" || calls != 1 {
+		t.Errorf("One page: got %q, %d calls, err %v", token, calls, err)
+	}
+
+	calls = 0
+	token, err = readPages("t1", true, 0, page(&calls))
+	if err != nil || token != "" || calls != 2 {
+		t.Errorf("All pages from t1: got %q, %d calls, err %v", token, calls, err)
+	}
+
+	calls = 0
+	token, err = readPages("", false, 5, page(&calls))
+	if err != nil || token != "t1" || calls != 1 {
+		t.Errorf("One page over the limit: got %q, %d calls, err %v", token, calls, err)
+	}
+
+	calls = 0
+	if _, err = readPages("", true, 25, page(&calls)); err == nil {
+		t.Errorf("Exceeding the limit did not return an error")
+	}
+	if calls != 3 {
+		t.Errorf("Exceeding the limit took %d calls", calls)
+	}
+}
+
+func TestClosestMatch(t *testing.T) {
+	values := []string{"web-browsing", "ssl", "ssh", "dns"}
+
+	for s, want := range map[string]string{
+		"web-browsng":  "web-browsing",
+		"Web-Browsing": "web-browsing",
+		"ss":           "ssh",
+		"dnss":         "dns",
+	} {
+		if got := closestMatch(s, values); got != want {
+			t.Errorf("%q: got %q, expected %q", s, got, want)
+		}
+	}
+
+	if got := closestMatch("x", nil); got != "" {
+		t.Errorf("No values: got %q", got)
+	}
+}
@@ -2,9 +2,9 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"time"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/rule/stack"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -20,11 +20,12 @@ func dataSourceValidateRulestack() *schema.Resource {
 		ReadContext: readValidateRulestack,
 
 		Timeouts: &schema.ResourceTimeout{
-			Create: schema.DefaultTimeout(15 * time.Minute),
+			Read: schema.DefaultTimeout(15 * time.Minute),
 		},
 
 		Schema: map[string]*schema.Schema{
-			RulestackName: rsSchema(),
+			RulestackName:   rsSchema(),
+			"fail_on_error": failOnErrorSchema(),
 			"state": {
 				Type:        schema.TypeString,
 				Computed:    true,
@@ -65,7 +66,11 @@ func readValidateRulestack(ctx context.Context, d *schema.ResourceData, meta int
 	var ans stack.CommitStatus
 	pending := "Pending"
 
-	svc := stack.NewClient(meta.(*awsngfw.Client))
+	con, err := apiClient(ctx, d, meta)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	svc := stack.NewClient(con)
 	name := d.Get(RulestackName).(string)
 
 	req := stack.ReadInput{
@@ -94,22 +99,16 @@ func readValidateRulestack(ctx context.Context, d *schema.ResourceData, meta int
 	}
 
 	// Wait until the status is not Pending.
-	for {
-		tflog.Info(
-			ctx, "getting validation status",
-			RulestackName, name,
-		)
-
+	_, err = pollStatus(ctx, fmt.Sprintf("rulestack %q validation", name), func() (string, bool, error) {
 		ans, err = svc.CommitStatus(ctx, name)
 		if err != nil {
-			return diag.FromErr(err)
-		}
-
-		if ans.Response.ValidationStatus != pending {
-			break
+			return "", false, err
 		}
 
-		time.Sleep(1 * time.Second)
+		return ans.Response.ValidationStatus, ans.Response.ValidationStatus != pending, nil
+	})
+	if err != nil {
+		return diag.FromErr(err)
 	}
 
 	d.SetId(name)
@@ -120,5 +119,9 @@ func readValidateRulestack(ctx context.Context, d *schema.ResourceData, meta int
 	d.Set("commit_errors", ans.Response.CommitMessages)
 	d.Set("validation_errors", ans.Response.ValidationMessages)
 
+	if d.Get("fail_on_error").(bool) {
+		return commitStatusDiagnostics(name, ans, false)
+	}
+
 	return nil
 }